@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+
+	"golang.org/x/crypto/blake2b"
+
+	"advent2019/progress"
+)
+
+var hashFuncs = map[string]func() hash.Hash{
+	"md5":    md5.New,
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+	"blake2b": func() hash.Hash {
+		h, _ := blake2b.New512(nil) // only fails on a bad key
+		return h
+	},
+}
+
+func hashNames() []string {
+	names := make([]string, 0, len(hashFuncs))
+	for name := range hashFuncs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// parseHashes parses the -hash list, e.g. "sha256,md5"
+func parseHashes(s string) ([]string, error) {
+	var algos []string
+	for _, name := range strings.Split(s, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		if _, ok := hashFuncs[name]; !ok {
+			return nil, fmt.Errorf("unknown hash - %s", name)
+		}
+		algos = append(algos, name)
+	}
+	return algos, nil
+}
+
+// sniffLen is how much http.DetectContentType looks at
+const sniffLen = 512
+
+// headWriter keeps the first sniffLen bytes written to it
+type headWriter struct {
+	buf []byte
+}
+
+func (w *headWriter) Write(p []byte) (int, error) {
+	if n := sniffLen - len(w.buf); n > 0 {
+		if n > len(p) {
+			n = len(p)
+		}
+		w.buf = append(w.buf, p[:n]...)
+	}
+	return len(p), nil
+}
+
+// addContentInfo reads path once, adding the digests in algos and, if sniff
+// is set, the content type to info. Bytes read are added to rep.
+func addContentInfo(info *FileInfo, path string, algos []string, sniff bool, rep *progress.Reporter) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	head := &headWriter{}
+	writers := []io.Writer{head}
+	hashes := make([]hash.Hash, len(algos))
+	for i, name := range algos {
+		hashes[i] = hashFuncs[name]()
+		writers = append(writers, hashes[i])
+	}
+
+	r := io.Reader(file)
+	if len(algos) == 0 {
+		// Only sniffing, no need to read the whole file
+		r = io.LimitReader(file, sniffLen)
+	}
+	if _, err := io.Copy(io.MultiWriter(writers...), progress.NewReader(r, rep)); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	if len(algos) > 0 {
+		info.Hashes = make(map[string]string, len(algos))
+	}
+	for i, name := range algos {
+		info.Hashes[name] = hex.EncodeToString(hashes[i].Sum(nil))
+	}
+	if sniff {
+		info.ContentType = contentType(head.buf)
+	}
+	return nil
+}
+
+// magic is a file signature at a given offset
+type magic struct {
+	offset int
+	sig    string
+	mime   string
+}
+
+// magics covers common formats http.DetectContentType doesn't know about
+var magics = []magic{
+	{0, "\xfd7zXZ\x00", "application/x-xz"},
+	{0, "BZh", "application/x-bzip2"},
+	{0, "\x28\xb5\x2f\xfd", "application/zstd"},
+	{0, "7z\xbc\xaf\x27\x1c", "application/x-7z-compressed"},
+	{257, "ustar", "application/x-tar"},
+	{0, "II*\x00", "image/tiff"},
+	{0, "MM\x00*", "image/tiff"},
+	{4, "ftypheic", "image/heic"},
+	{4, "ftypavif", "image/avif"},
+	{0, "8BPS", "image/vnd.adobe.photoshop"},
+	{0, "\x7fELF", "application/x-elf"},
+}
+
+// contentType returns the MIME type of a file starting with head
+func contentType(head []byte) string {
+	for _, m := range magics {
+		end := m.offset + len(m.sig)
+		if end <= len(head) && bytes.Equal(head[m.offset:end], []byte(m.sig)) {
+			return m.mime
+		}
+	}
+	return http.DetectContentType(head)
+}
@@ -0,0 +1,437 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"advent2019/progress"
+)
+
+const usage = `usage: %s PATH [PATH ...]
+       %s -watch PATH [PATH ...]
+file information, directories are walked recursively
+
+-format takes a Go template executed for every entry with a FileInfo, e.g.
+  -format '{{.Path}} {{.Size}} {{.Mode}} {{with .Sys}}{{.User}}{{end}} {{index .Hashes "sha256"}}'
+
+options:
+`
+
+func main() {
+	var (
+		jsonOut, summary, sniff bool
+		watchMode               bool
+		interval                time.Duration
+		largest, workers        int
+		hashes, output          string
+		fieldList, format       string
+		progressMode            = progress.Quiet
+	)
+	flag.BoolVar(&jsonOut, "json", false, "output in JSON format, one object per line (same as -output ndjson)")
+	flag.StringVar(&output, "output", "text", fmt.Sprintf("output format (%s)", strings.Join(outputNames(), ", ")))
+	flag.StringVar(&fieldList, "fields", "", fmt.Sprintf("comma separated fields to output (%s)", strings.Join(fieldNames, ",")))
+	flag.StringVar(&format, "format", "", "Go template for each entry, overrides -output")
+	flag.BoolVar(&summary, "summary", false, "only print the aggregate statistics")
+	flag.IntVar(&largest, "largest", 5, "number of largest files to report")
+	flag.StringVar(&hashes, "hash", "", fmt.Sprintf("comma separated digests of file content to add (%s)", strings.Join(hashNames(), ",")))
+	flag.BoolVar(&sniff, "type", false, "detect the content type of files")
+	flag.IntVar(&workers, "workers", runtime.NumCPU(), "number of files to read in parallel")
+	flag.BoolVar(&watchMode, "watch", false, "watch PATHs and print a line when a file is created, deleted or its size, mode or modification time change")
+	flag.DurationVar(&interval, "interval", time.Second, "with -watch, how often to poll for changes")
+	flag.Var(&progressMode, "progress", "with -hash or -type, "+progress.ModeUsage+" on stderr")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), usage, os.Args[0], os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() < 1 {
+		log.Fatal("error: wrong number of arguments")
+	}
+	if workers < 1 {
+		workers = 1
+	}
+	if jsonOut {
+		output = "ndjson"
+	}
+	// CSV and templates have a row per entry and no room for the summary
+	if summary && (format != "" || output == "csv") {
+		log.Fatal("error: -summary can't be used with -output csv or -format")
+	}
+
+	if watchMode {
+		if interval <= 0 {
+			log.Fatal("error: -interval must be positive")
+		}
+		jsonEvents := output == "json" || output == "ndjson"
+		if err := watch(flag.Args(), interval, os.Stdout, jsonEvents); err != nil {
+			log.Fatalf("error: %s", err)
+		}
+		return
+	}
+
+	algos, err := parseHashes(hashes)
+	if err != nil {
+		log.Fatalf("error: %s", err)
+	}
+	readContent := len(algos) > 0 || sniff
+
+	// The total grows as the walk finds files
+	rep := progress.New(os.Stderr, progressMode, "reading", 0)
+	rep.SetUnits(progress.Bytes)
+	if readContent && !summary {
+		rep.Start(context.Background())
+	}
+
+	fields, err := parseFields(fieldList, algos, sniff)
+	if err != nil {
+		log.Fatalf("error: %s", err)
+	}
+
+	var w writer
+	if format != "" {
+		w, err = newTemplateWriter(os.Stdout, format)
+	} else {
+		w, err = newWriter(output, os.Stdout, fields)
+	}
+	if err != nil {
+		log.Fatalf("error: %s", err)
+	}
+
+	// The walk feeds entries to workers (which read the content if needed)
+	// and, in walk order, to the writer below so output order is stable
+	st := newStats(largest)
+	jobs := make(chan *entry)
+	ordered := make(chan *entry, workers)
+	go func() {
+		defer close(ordered)
+		defer close(jobs)
+		for _, root := range flag.Args() {
+			filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+				var fi os.FileInfo
+				if err == nil {
+					fi, err = d.Info()
+				}
+				if err != nil {
+					ordered <- failedEntry(err)
+					return nil
+				}
+
+				st.add(path, fi)
+				if readContent && !summary && fi.Mode().IsRegular() {
+					if len(algos) > 0 {
+						rep.AddTotal(fi.Size())
+					} else {
+						rep.AddTotal(min(fi.Size(), sniffLen))
+					}
+				}
+				e := &entry{path: path, fi: fi, done: make(chan struct{})}
+				jobs <- e
+				ordered <- e
+				return nil
+			})
+		}
+	}()
+
+	for i := 0; i < workers; i++ {
+		go func() {
+			for e := range jobs {
+				if !summary {
+					e.info = newFileInfo(e.path, e.fi)
+					if readContent && e.fi.Mode().IsRegular() {
+						e.err = addContentInfo(e.info, e.path, algos, sniff, rep)
+					}
+				}
+				close(e.done)
+			}
+		}()
+	}
+
+	failed := false
+	for e := range ordered {
+		<-e.done
+		if e.err != nil {
+			log.Printf("error: %s", e.err)
+			failed = true
+			continue
+		}
+		if summary {
+			continue
+		}
+		if err := w.entry(e.info); err != nil {
+			log.Fatalf("error: %s", err)
+		}
+	}
+
+	if readContent && !summary {
+		rep.Finish()
+	}
+
+	// A single file needs no summary
+	var sum *Summary
+	if summary || st.files+st.dirs > 1 {
+		sum = st.summary()
+	}
+	if err := w.close(sum); err != nil {
+		log.Fatalf("error: %s", err)
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// entry is a file found by the walk, done is closed once info is filled
+type entry struct {
+	path string
+	fi   os.FileInfo
+	info *FileInfo
+	err  error
+	done chan struct{}
+}
+
+func failedEntry(err error) *entry {
+	done := make(chan struct{})
+	close(done)
+	return &entry{err: err, done: done}
+}
+
+// FileInfo is the metadata reported for a file
+type FileInfo struct {
+	Path        string
+	Size        int64
+	Dir         bool
+	Mode        os.FileMode
+	Modified    time.Time
+	LinkTarget  string            // symlinks only
+	Sys         *SysInfo          // nil if not supported on this platform
+	Hashes      map[string]string // algorithm -> hex digest, see -hash
+	ContentType string            // see -type
+}
+
+// SysInfo is the platform specific part of FileInfo
+type SysInfo struct {
+	UID, GID    uint32
+	User, Group string
+	Inode       uint64
+	Links       uint64
+	Accessed    time.Time
+	Changed     time.Time
+	Xattrs      map[string]string
+}
+
+// newFileInfo returns the metadata of path, fi is from Lstat so symlinks are
+// reported as such along with their target
+func newFileInfo(path string, fi os.FileInfo) *FileInfo {
+	info := &FileInfo{
+		Path:     path,
+		Size:     fi.Size(),
+		Dir:      fi.IsDir(),
+		Mode:     fi.Mode(),
+		Modified: fi.ModTime(),
+		Sys:      sysInfo(path, fi),
+	}
+
+	if fi.Mode()&os.ModeSymlink != 0 {
+		if target, err := os.Readlink(path); err == nil {
+			info.LinkTarget = target
+		}
+	}
+	return info
+}
+
+// fieldNames are the output fields in their fixed order
+var fieldNames = []string{
+	"path", "size", "dir", "mode", "modified", "link_target",
+	"uid", "gid", "user", "group", "inode", "links", "accessed", "changed", "xattrs",
+	"md5", "sha1", "sha256", "sha512", "blake2b", "content_type",
+}
+
+// field returns the value of the named field, ok is false if it's not set
+func (f *FileInfo) field(name string) (v interface{}, ok bool) {
+	switch name {
+	case "path":
+		return f.Path, true
+	case "size":
+		return f.Size, true
+	case "dir":
+		return f.Dir, true
+	case "mode":
+		return f.Mode.String(), true
+	case "modified":
+		return f.Modified, true
+	case "link_target":
+		return f.LinkTarget, f.LinkTarget != ""
+	case "content_type":
+		return f.ContentType, f.ContentType != ""
+	}
+
+	if _, ok := hashFuncs[name]; ok {
+		sum, ok := f.Hashes[name]
+		return sum, ok
+	}
+
+	s := f.Sys
+	if s == nil {
+		return nil, false
+	}
+	switch name {
+	case "uid":
+		return s.UID, true
+	case "gid":
+		return s.GID, true
+	case "user":
+		return s.User, true
+	case "group":
+		return s.Group, true
+	case "inode":
+		return s.Inode, true
+	case "links":
+		return s.Links, true
+	case "accessed":
+		return s.Accessed, true
+	case "changed":
+		return s.Changed, true
+	case "xattrs":
+		return s.Xattrs, len(s.Xattrs) > 0
+	}
+	return nil, false
+}
+
+// parseFields parses the -fields list. The default is every field that can
+// be set given the platform and the -hash and -type options.
+func parseFields(s string, algos []string, sniff bool) ([]string, error) {
+	known := make(map[string]bool)
+	for _, name := range fieldNames {
+		known[name] = true
+	}
+
+	if s != "" {
+		var fields []string
+		for _, name := range strings.Split(s, ",") {
+			name = strings.TrimSpace(name)
+			if !known[name] {
+				return nil, fmt.Errorf("unknown field - %s", name)
+			}
+			fields = append(fields, name)
+		}
+		return fields, nil
+	}
+
+	enabled := map[string]bool{"content_type": sniff}
+	for name := range hashFuncs {
+		enabled[name] = false
+	}
+	for _, name := range algos {
+		enabled[name] = true
+	}
+	if !hasSysInfo {
+		for _, name := range []string{"uid", "gid", "user", "group", "inode", "links", "accessed", "changed", "xattrs"} {
+			enabled[name] = false
+		}
+	}
+
+	var fields []string
+	for _, name := range fieldNames {
+		if on, ok := enabled[name]; !ok || on {
+			fields = append(fields, name)
+		}
+	}
+	return fields, nil
+}
+
+// FileSize is a file in Summary.Largest
+type FileSize struct {
+	Path string `json:"path" yaml:"path"`
+	Size int64  `json:"size" yaml:"size"`
+}
+
+// ExtStats is the number and total size of files with an extension
+type ExtStats struct {
+	Extension string `json:"extension" yaml:"extension"`
+	Files     int    `json:"files" yaml:"files"`
+	Size      int64  `json:"size" yaml:"size"`
+}
+
+// Summary are the aggregate statistics of a walk
+type Summary struct {
+	Files      int        `json:"files" yaml:"files"`
+	Dirs       int        `json:"dirs" yaml:"dirs"`
+	TotalSize  int64      `json:"total_size" yaml:"total_size"`
+	Largest    []FileSize `json:"largest" yaml:"largest"`
+	Extensions []ExtStats `json:"extensions" yaml:"extensions"` // biggest first
+}
+
+// stats aggregates the entries seen by the walk, similar to du
+type stats struct {
+	files, dirs int
+	size        int64
+	largest     []FileSize // sorted by size, biggest first
+	maxLargest  int
+	byExt       map[string]*ExtStats
+}
+
+func newStats(largest int) *stats {
+	return &stats{maxLargest: largest, byExt: make(map[string]*ExtStats)}
+}
+
+func (s *stats) add(path string, fi os.FileInfo) {
+	if fi.IsDir() {
+		s.dirs++
+		return
+	}
+
+	s.files++
+	s.size += fi.Size()
+
+	ext := strings.ToLower(filepath.Ext(path))
+	if ext == "" {
+		ext = "(none)"
+	}
+	es, ok := s.byExt[ext]
+	if !ok {
+		es = &ExtStats{Extension: ext}
+		s.byExt[ext] = es
+	}
+	es.Files++
+	es.Size += fi.Size()
+
+	// Insert into largest, keeping it sorted and at most maxLargest long
+	i := sort.Search(len(s.largest), func(i int) bool { return s.largest[i].Size < fi.Size() })
+	if i >= s.maxLargest {
+		return
+	}
+	s.largest = append(s.largest, FileSize{})
+	copy(s.largest[i+1:], s.largest[i:])
+	s.largest[i] = FileSize{path, fi.Size()}
+	if len(s.largest) > s.maxLargest {
+		s.largest = s.largest[:s.maxLargest]
+	}
+}
+
+func (s *stats) summary() *Summary {
+	exts := make([]ExtStats, 0, len(s.byExt))
+	for _, es := range s.byExt {
+		exts = append(exts, *es)
+	}
+	sort.Slice(exts, func(i, j int) bool {
+		if exts[i].Size != exts[j].Size {
+			return exts[i].Size > exts[j].Size
+		}
+		return exts[i].Extension < exts[j].Extension
+	})
+
+	return &Summary{
+		Files:      s.files,
+		Dirs:       s.dirs,
+		TotalSize:  s.size,
+		Largest:    append([]FileSize{}, s.largest...),
+		Extensions: exts,
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/hex"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/sys/unix"
+)
+
+// hasSysInfo is set on platforms that implement sysInfo
+const hasSysInfo = true
+
+// sysInfo returns ownership, inode, link count, access/change times and
+// extended attributes from the Linux stat structure
+func sysInfo(path string, fi os.FileInfo) *SysInfo {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil
+	}
+
+	info := &SysInfo{
+		UID:      st.Uid,
+		GID:      st.Gid,
+		User:     userName(st.Uid),
+		Group:    groupName(st.Gid),
+		Inode:    st.Ino,
+		Links:    uint64(st.Nlink),
+		Accessed: time.Unix(st.Atim.Unix()),
+		Changed:  time.Unix(st.Ctim.Unix()),
+	}
+
+	info.Xattrs = xattrs(path)
+	return info
+}
+
+var (
+	namesMu sync.Mutex
+	users   = make(map[uint32]string)
+	groups  = make(map[uint32]string)
+)
+
+// userName returns the name of uid, or the number if it can't be resolved.
+// Lookups are cached since a walk sees the same few owners over and over.
+func userName(uid uint32) string {
+	namesMu.Lock()
+	defer namesMu.Unlock()
+
+	name, ok := users[uid]
+	if !ok {
+		id := strconv.FormatUint(uint64(uid), 10)
+		name = id
+		if u, err := user.LookupId(id); err == nil {
+			name = u.Username
+		}
+		users[uid] = name
+	}
+	return name
+}
+
+func groupName(gid uint32) string {
+	namesMu.Lock()
+	defer namesMu.Unlock()
+
+	name, ok := groups[gid]
+	if !ok {
+		id := strconv.FormatUint(uint64(gid), 10)
+		name = id
+		if g, err := user.LookupGroupId(id); err == nil {
+			name = g.Name
+		}
+		groups[gid] = name
+	}
+	return name
+}
+
+// xattrs returns the extended attributes of path, of the link itself for a
+// symlink like the rest of the entry. Values that aren't valid UTF-8 are hex
+// encoded with a "0x" prefix.
+func xattrs(path string) map[string]string {
+	size, err := unix.Llistxattr(path, nil)
+	if err != nil || size == 0 {
+		return nil
+	}
+	buf := make([]byte, size)
+	size, err = unix.Llistxattr(path, buf)
+	if err != nil {
+		return nil
+	}
+
+	attrs := make(map[string]string)
+	for _, name := range strings.Split(strings.TrimRight(string(buf[:size]), "\x00"), "\x00") {
+		vsize, err := unix.Lgetxattr(path, name, nil)
+		if err != nil {
+			continue
+		}
+		val := make([]byte, vsize)
+		if vsize, err = unix.Lgetxattr(path, name, val); err != nil {
+			continue
+		}
+		val = val[:vsize]
+
+		if utf8.Valid(val) {
+			attrs[name] = string(val)
+		} else {
+			attrs[name] = "0x" + hex.EncodeToString(val)
+		}
+	}
+	return attrs
+}
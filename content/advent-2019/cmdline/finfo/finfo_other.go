@@ -0,0 +1,14 @@
+//go:build !linux
+// +build !linux
+
+package main
+
+import "os"
+
+// hasSysInfo is set on platforms that implement sysInfo
+const hasSysInfo = false
+
+// sysInfo returns platform specific metadata, only implemented on Linux
+func sysInfo(path string, fi os.FileInfo) *SysInfo {
+	return nil
+}
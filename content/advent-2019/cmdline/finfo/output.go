@@ -0,0 +1,269 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/template"
+
+	"gopkg.in/yaml.v2"
+)
+
+// writer outputs entries as they come, close writes the summary (which may
+// be nil) and anything the format needs at the end
+type writer interface {
+	entry(f *FileInfo) error
+	close(s *Summary) error
+}
+
+var outputs = map[string]func(w io.Writer, fields []string) writer{
+	"text":   func(w io.Writer, fields []string) writer { return &textWriter{w, fields} },
+	"json":   func(w io.Writer, fields []string) writer { return &jsonWriter{w: w, fields: fields} },
+	"ndjson": func(w io.Writer, fields []string) writer { return &ndjsonWriter{json.NewEncoder(w), fields} },
+	"yaml":   func(w io.Writer, fields []string) writer { return &yamlWriter{w: w, fields: fields} },
+	"csv":    newCSVWriter,
+}
+
+func outputNames() []string {
+	names := make([]string, 0, len(outputs))
+	for name := range outputs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func newWriter(name string, w io.Writer, fields []string) (writer, error) {
+	newWriter, ok := outputs[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown output format - %s", name)
+	}
+	return newWriter(w, fields), nil
+}
+
+// kv is a field of a record
+type kv struct {
+	key   string
+	value interface{}
+}
+
+// record is a FileInfo with the selected fields in order
+type record []kv
+
+func newRecord(f *FileInfo, fields []string) record {
+	r := make(record, 0, len(fields))
+	for _, name := range fields {
+		if v, ok := f.field(name); ok {
+			r = append(r, kv{name, v})
+		}
+	}
+	return r
+}
+
+func (r record) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, f := range r {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, _ := json.Marshal(f.key)
+		val, err := json.Marshal(f.value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(val)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+func (r record) MarshalYAML() (interface{}, error) {
+	m := make(yaml.MapSlice, len(r))
+	for i, f := range r {
+		m[i] = yaml.MapItem{Key: f.key, Value: f.value}
+	}
+	return m, nil
+}
+
+// formatValue formats a field value for text and CSV output, maps (xattrs)
+// become sorted key=value pairs
+func formatValue(v interface{}) string {
+	m, ok := v.(map[string]string)
+	if !ok {
+		return fmt.Sprint(v)
+	}
+
+	pairs := make([]string, 0, len(m))
+	for k, v := range m {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ";")
+}
+
+// textWriter writes "key: value" lines, with a blank line after each entry
+type textWriter struct {
+	w      io.Writer
+	fields []string
+}
+
+func (t *textWriter) entry(f *FileInfo) error {
+	for _, field := range newRecord(f, t.fields) {
+		if _, err := fmt.Fprintf(t.w, "%s: %s\n", field.key, formatValue(field.value)); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintln(t.w)
+	return err
+}
+
+func (t *textWriter) close(s *Summary) error {
+	if s == nil {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "files: %d\ndirs: %d\ntotal_size: %d\n", s.Files, s.Dirs, s.TotalSize)
+	fmt.Fprintln(&buf, "largest:")
+	for _, f := range s.Largest {
+		fmt.Fprintf(&buf, "  %d\t%s\n", f.Size, f.Path)
+	}
+	fmt.Fprintln(&buf, "extensions:")
+	for _, e := range s.Extensions {
+		fmt.Fprintf(&buf, "  %s\t%d files\t%d bytes\n", e.Extension, e.Files, e.Size)
+	}
+	_, err := buf.WriteTo(t.w)
+	return err
+}
+
+// jsonWriter writes a single {"entries": [...], "summary": {...}} document
+type jsonWriter struct {
+	w       io.Writer
+	fields  []string
+	entries []record
+}
+
+func (j *jsonWriter) entry(f *FileInfo) error {
+	j.entries = append(j.entries, newRecord(f, j.fields))
+	return nil
+}
+
+func (j *jsonWriter) close(s *Summary) error {
+	doc := struct {
+		Entries []record `json:"entries"`
+		Summary *Summary `json:"summary,omitempty"`
+	}{j.entries, s}
+	if doc.Entries == nil {
+		doc.Entries = []record{}
+	}
+
+	enc := json.NewEncoder(j.w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(doc)
+}
+
+// ndjsonWriter writes one JSON object per line, the summary is the last
+// line wrapped as {"summary": {...}}
+type ndjsonWriter struct {
+	enc    *json.Encoder
+	fields []string
+}
+
+func (n *ndjsonWriter) entry(f *FileInfo) error {
+	return n.enc.Encode(newRecord(f, n.fields))
+}
+
+func (n *ndjsonWriter) close(s *Summary) error {
+	if s == nil {
+		return nil
+	}
+	return n.enc.Encode(map[string]*Summary{"summary": s})
+}
+
+// yamlWriter writes a single document with entries and summary
+type yamlWriter struct {
+	w       io.Writer
+	fields  []string
+	entries []record
+}
+
+func (y *yamlWriter) entry(f *FileInfo) error {
+	y.entries = append(y.entries, newRecord(f, y.fields))
+	return nil
+}
+
+func (y *yamlWriter) close(s *Summary) error {
+	doc := struct {
+		Entries []record `yaml:"entries"`
+		Summary *Summary `yaml:"summary,omitempty"`
+	}{y.entries, s}
+
+	data, err := yaml.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	_, err = y.w.Write(data)
+	return err
+}
+
+// csvWriter writes a header with the selected fields and a row per entry.
+// There's no room for the summary in a CSV table so it's not written.
+type csvWriter struct {
+	cw     *csv.Writer
+	fields []string
+}
+
+func newCSVWriter(w io.Writer, fields []string) writer {
+	cw := csv.NewWriter(w)
+	cw.Write(fields)
+	return &csvWriter{cw, fields}
+}
+
+func (c *csvWriter) entry(f *FileInfo) error {
+	row := make([]string, len(c.fields))
+	for i, name := range c.fields {
+		if v, ok := f.field(name); ok {
+			row[i] = formatValue(v)
+		}
+	}
+	return c.cw.Write(row)
+}
+
+// close leaves out the summary, it doesn't fit the columns
+func (c *csvWriter) close(s *Summary) error {
+	c.cw.Flush()
+	return c.cw.Error()
+}
+
+// templateWriter executes a Go template for each entry, like stat --format
+type templateWriter struct {
+	w    io.Writer
+	tmpl *template.Template
+}
+
+func newTemplateWriter(w io.Writer, format string) (writer, error) {
+	if !strings.HasSuffix(format, "\n") {
+		format += "\n"
+	}
+	tmpl, err := template.New("format").Parse(format)
+	if err != nil {
+		return nil, err
+	}
+	return &templateWriter{w, tmpl}, nil
+}
+
+func (t *templateWriter) entry(f *FileInfo) error {
+	return t.tmpl.Execute(t.w, f)
+}
+
+// close leaves out the summary, the template is for entries
+func (t *templateWriter) close(s *Summary) error {
+	return nil
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// fileState is what -watch compares between scans
+type fileState struct {
+	size     int64
+	mode     os.FileMode
+	modified time.Time
+}
+
+// Change is a field of a file that changed between scans
+type Change struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+// Event is a file being created, deleted or changed
+type Event struct {
+	Time    time.Time `json:"time"`
+	Event   string    `json:"event"` // created, deleted or changed
+	Path    string    `json:"path"`
+	Changes []Change  `json:"changes,omitempty"`
+}
+
+func (e Event) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %-7s %s", e.Time.Format(time.RFC3339), e.Event, e.Path)
+	for i, c := range e.Changes {
+		sep := ", "
+		if i == 0 {
+			sep = " "
+		}
+		fmt.Fprintf(&b, "%s%s %s -> %s", sep, c.Field, c.Old, c.New)
+	}
+	return b.String()
+}
+
+// scan returns the state of every entry under roots, entries that vanish
+// during the walk are skipped
+func scan(roots []string) map[string]fileState {
+	states := make(map[string]fileState)
+	for _, root := range roots {
+		filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+			fi, err := d.Info()
+			if err != nil {
+				return nil
+			}
+			states[path] = fileState{fi.Size(), fi.Mode(), fi.ModTime()}
+			return nil
+		})
+	}
+	return states
+}
+
+// diff returns the events between two scans, sorted by path
+func diff(old, cur map[string]fileState, now time.Time) []Event {
+	var events []Event
+	for path, s := range cur {
+		o, ok := old[path]
+		if !ok {
+			events = append(events, Event{Time: now, Event: "created", Path: path})
+			continue
+		}
+
+		var changes []Change
+		if o.size != s.size {
+			changes = append(changes, Change{"size", fmt.Sprint(o.size), fmt.Sprint(s.size)})
+		}
+		if o.mode != s.mode {
+			changes = append(changes, Change{"mode", o.mode.String(), s.mode.String()})
+		}
+		if !o.modified.Equal(s.modified) {
+			changes = append(changes, Change{"modified", o.modified.Format(time.RFC3339Nano), s.modified.Format(time.RFC3339Nano)})
+		}
+		if len(changes) > 0 {
+			events = append(events, Event{Time: now, Event: "changed", Path: path, Changes: changes})
+		}
+	}
+	for path := range old {
+		if _, ok := cur[path]; !ok {
+			events = append(events, Event{Time: now, Event: "deleted", Path: path})
+		}
+	}
+
+	sort.Slice(events, func(i, j int) bool { return events[i].Path < events[j].Path })
+	return events
+}
+
+// watch rescans roots every interval, or sooner when the platform notifier
+// reports a change, and writes an event for every difference. It only
+// returns on a write error.
+func watch(roots []string, interval time.Duration, w io.Writer, jsonOut bool) error {
+	n, err := newNotifier()
+	if err != nil {
+		// Polling still works
+		fmt.Fprintf(os.Stderr, "warning: %s, polling every %s\n", err, interval)
+	}
+	if n != nil {
+		defer n.close()
+	}
+
+	enc := json.NewEncoder(w)
+	write := func(e Event) error {
+		if jsonOut {
+			return enc.Encode(e)
+		}
+		_, err := fmt.Fprintln(w, e)
+		return err
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	states := scan(roots)
+	for {
+		if n != nil {
+			n.watch(roots, states)
+		}
+
+		var wake <-chan struct{}
+		if n != nil {
+			wake = n.events()
+		}
+		select {
+		case <-ticker.C:
+		case <-wake:
+			// Let a burst of changes (e.g. a build) settle before scanning
+			time.Sleep(50 * time.Millisecond)
+		}
+
+		cur := scan(roots)
+		for _, e := range diff(states, cur, time.Now()) {
+			if err := write(e); err != nil {
+				return err
+			}
+		}
+		states = cur
+	}
+}
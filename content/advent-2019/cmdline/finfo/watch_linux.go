@@ -0,0 +1,86 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// notifier wakes up watch when inotify reports a change
+type notifier struct {
+	fd      int
+	watched map[string]bool
+	wake    chan struct{}
+}
+
+const inotifyMask = syscall.IN_CREATE | syscall.IN_DELETE | syscall.IN_MODIFY | syscall.IN_ATTRIB |
+	syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO | syscall.IN_DELETE_SELF | syscall.IN_MOVE_SELF
+
+func newNotifier() (*notifier, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC)
+	if err != nil {
+		return nil, os.NewSyscallError("inotify_init1", err)
+	}
+
+	n := &notifier{fd: fd, watched: make(map[string]bool), wake: make(chan struct{}, 1)}
+	go n.read()
+	return n, nil
+}
+
+// read only cares that something happened, watch rescans to find out what
+func (n *notifier) read() {
+	buf := make([]byte, 64*1024)
+	for {
+		size, err := syscall.Read(n.fd, buf)
+		if err == syscall.EINTR {
+			continue
+		}
+		if err != nil || size <= 0 {
+			return
+		}
+		select {
+		case n.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (n *notifier) events() <-chan struct{} {
+	return n.wake
+}
+
+// watch adds an inotify watch for every directory in states it doesn't
+// watch yet, and for the parent of roots that are files. A directory watch
+// covers the files in it. Watches of deleted directories go away by
+// themselves.
+func (n *notifier) watch(roots []string, states map[string]fileState) {
+	dirs := make(map[string]bool)
+	for path, s := range states {
+		if s.mode.IsDir() {
+			dirs[path] = true
+		}
+	}
+	for _, root := range roots {
+		if s, ok := states[root]; !ok || !s.mode.IsDir() {
+			dirs[filepath.Dir(root)] = true
+		}
+	}
+
+	for dir := range dirs {
+		if n.watched[dir] {
+			continue
+		}
+		if _, err := syscall.InotifyAddWatch(n.fd, dir, inotifyMask); err == nil {
+			n.watched[dir] = true
+		}
+	}
+	for dir := range n.watched {
+		if !dirs[dir] {
+			delete(n.watched, dir)
+		}
+	}
+}
+
+func (n *notifier) close() {
+	syscall.Close(n.fd)
+}
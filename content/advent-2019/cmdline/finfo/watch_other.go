@@ -0,0 +1,15 @@
+//go:build !linux
+// +build !linux
+
+package main
+
+// notifier is only implemented on Linux (inotify), other platforms poll
+type notifier struct{}
+
+func newNotifier() (*notifier, error) {
+	return nil, nil
+}
+
+func (n *notifier) events() <-chan struct{}                           { return nil }
+func (n *notifier) watch(roots []string, states map[string]fileState) {}
+func (n *notifier) close()                                            {}
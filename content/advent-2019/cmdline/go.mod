@@ -1,5 +1,26 @@
 module advent2019
 
-go 1.13
+go 1.21
 
-require github.com/cheggaaa/pb/v3 v3.0.2
+require (
+	github.com/go-sql-driver/mysql v1.8.1
+	github.com/lib/pq v1.10.9
+	github.com/mattn/go-isatty v0.0.20
+	github.com/mattn/go-runewidth v0.0.4
+	golang.org/x/crypto v0.21.0
+	golang.org/x/sys v0.22.0
+	golang.org/x/term v0.22.0
+	gopkg.in/yaml.v2 v2.2.7
+	modernc.org/sqlite v1.34.5
+)
+
+require (
+	filippo.io/edwards25519 v1.1.0 // indirect
+	github.com/dustin/go-humanize v1.0.1 // indirect
+	github.com/google/uuid v1.6.0 // indirect
+	github.com/ncruces/go-strftime v0.1.9 // indirect
+	github.com/remyoudompheng/bigfft v0.0.0-20230129092748-24d4a6f8daec // indirect
+	modernc.org/libc v1.55.3 // indirect
+	modernc.org/mathutil v1.6.0 // indirect
+	modernc.org/memory v1.8.0 // indirect
+)
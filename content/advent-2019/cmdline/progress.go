@@ -1,20 +1,123 @@
 package main
 
 import (
+	"context"
 	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"runtime"
+	"sync"
 	"time"
 
-	"github.com/cheggaaa/pb/v3"
+	"advent2019/progress"
 )
 
+const usage = `usage: %s [FILE ...]
+Show progress counting to 100, or reading FILEs (- for stdin) in parallel
+
+Options:
+`
+
 func main() {
+	var (
+		mode    progress.Mode
+		workers int
+	)
+	flag.Var(&mode, "progress", progress.ModeUsage)
+	flag.IntVar(&workers, "workers", runtime.NumCPU(), "number of FILEs to read in parallel")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), usage, os.Args[0])
+		flag.PrintDefaults()
+	}
 	flag.Parse()
+
+	if flag.NArg() > 0 {
+		if workers < 1 {
+			workers = 1
+		}
+		if err := readFiles(flag.Args(), workers, mode); err != nil {
+			log.Fatalf("error: %s", err)
+		}
+		return
+	}
+
 	count := 100
-	bar := pb.StartNew(count)
+	r := progress.New(os.Stderr, mode, "working", int64(count))
+	r.Start(context.Background())
 	for i := 0; i < count; i++ {
 		time.Sleep(100 * time.Millisecond)
-		bar.Increment()
+		r.Increment()
+	}
+	r.Finish()
+}
+
+// readFiles reads files with a line per worker showing the file it's on and
+// a line for the total, which is unknown when reading from stdin
+func readFiles(files []string, workers int, mode progress.Mode) error {
+	var total int64
+	for _, name := range files {
+		if name == "-" {
+			total = 0
+			break
+		}
+		fi, err := os.Stat(name)
+		if err != nil {
+			return err
+		}
+		total += fi.Size()
 	}
-	bar.Finish()
+	pool := progress.NewPool(os.Stderr, mode, "total", total)
+	pool.Overall().SetUnits(progress.Bytes)
+	pool.Start(context.Background())
+	defer pool.Finish()
+
+	names := make(chan string)
+	errs := make(chan error, len(files))
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for name := range names {
+				errs <- readFile(name, pool)
+			}
+		}()
+	}
+	for _, name := range files {
+		names <- name
+	}
+	close(names)
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readFile(name string, pool *progress.Pool) error {
+	file, size := os.Stdin, int64(0)
+	if name != "-" {
+		var err error
+		if file, err = os.Open(name); err != nil {
+			return err
+		}
+		defer file.Close()
+		if fi, err := file.Stat(); err == nil {
+			size = fi.Size()
+		}
+	}
+
+	r := pool.Add(name, size)
+	r.SetUnits(progress.Bytes)
+	defer r.Finish()
 
+	// The overall reporter counts what's written to it
+	_, err := io.Copy(pool.Overall(), progress.NewReader(file, r))
+	return err
 }
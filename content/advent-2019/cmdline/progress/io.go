@@ -0,0 +1,53 @@
+package progress
+
+import "io"
+
+// Reader reports the bytes read from it
+type Reader struct {
+	r   io.Reader
+	rep *Reporter
+}
+
+// NewReader returns a Reader reading from r and adding the bytes read to rep
+func NewReader(r io.Reader, rep *Reporter) *Reader {
+	return &Reader{r, rep}
+}
+
+func (r *Reader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.rep.Add(int64(n))
+	return n, err
+}
+
+// Close closes the underlying reader if it's an io.Closer
+func (r *Reader) Close() error {
+	if c, ok := r.r.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// Writer reports the bytes written to it
+type Writer struct {
+	w   io.Writer
+	rep *Reporter
+}
+
+// NewWriter returns a Writer writing to w and adding the bytes written to rep
+func NewWriter(w io.Writer, rep *Reporter) *Writer {
+	return &Writer{w, rep}
+}
+
+func (w *Writer) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.rep.Add(int64(n))
+	return n, err
+}
+
+// Close closes the underlying writer if it's an io.Closer
+func (w *Writer) Close() error {
+	if c, ok := w.w.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package progress
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/mattn/go-isatty"
+	"golang.org/x/term"
+)
+
+// Mode is how progress is shown
+type Mode int
+
+const (
+	Auto     Mode = iota // Terminal or Plain, depending on the output
+	Terminal             // animated, redrawing the line
+	Plain                // a line every PlainInterval, for pipes and CI logs
+	Quiet                // nothing
+)
+
+var modeNames = []string{"auto", "terminal", "plain", "quiet"}
+
+// ModeUsage is a flag usage message listing the modes
+var ModeUsage = fmt.Sprintf("how to show progress (%s)", strings.Join(modeNames, ", "))
+
+func (m Mode) String() string {
+	if m < 0 || int(m) >= len(modeNames) {
+		return fmt.Sprintf("Mode(%d)", int(m))
+	}
+	return modeNames[m]
+}
+
+// Set implements flag.Value
+func (m *Mode) Set(s string) error {
+	for i, name := range modeNames {
+		if s == name {
+			*m = Mode(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown mode - %s", s)
+}
+
+// Resolve returns m, or if it's Auto the mode for w: Terminal for a terminal
+// which can move the cursor (TERM isn't dumb), Plain otherwise
+func (m Mode) Resolve(w io.Writer) Mode {
+	if m != Auto {
+		return m
+	}
+	if IsTerminal(w) && os.Getenv("TERM") != "dumb" {
+		return Terminal
+	}
+	return Plain
+}
+
+// Color reports whether colors may be used, see https://no-color.org
+func Color() bool {
+	return os.Getenv("NO_COLOR") == "" && os.Getenv("TERM") != "dumb"
+}
+
+// IsTerminal reports whether w is a terminal
+func IsTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	return isatty.IsTerminal(f.Fd()) || isatty.IsCygwinTerminal(f.Fd())
+}
+
+// TermWidth returns the width of the terminal w, 0 if unknown
+func TermWidth(w io.Writer) int {
+	f, ok := w.(*os.File)
+	if !ok {
+		return 0
+	}
+	width, _, err := term.GetSize(int(f.Fd()))
+	if err != nil {
+		return 0
+	}
+	return width
+}
@@ -0,0 +1,169 @@
+package progress
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+const clearDown = "\x1b[J"
+
+// Pool shows a line per reporter, e.g. one per worker, with an overall
+// reporter on the last line. On a terminal the lines are redrawn together
+// by moving the cursor up. Reporters can be added and removed at any time
+// and it's safe for concurrent use.
+type Pool struct {
+	mu        sync.Mutex
+	w         io.Writer
+	mode      Mode
+	overall   *Reporter
+	reporters []*Reporter
+	lines     int // drawn last time
+
+	stop chan struct{} // nil when not running
+	done chan struct{} // closed when the goroutine exits
+}
+
+// NewPool returns a pool writing to w whose overall reporter has message and
+// total, mode Auto picks the mode from w
+func NewPool(w io.Writer, mode Mode, message string, total int64) *Pool {
+	p := &Pool{w: w, mode: mode.Resolve(w)}
+	p.overall = p.newReporter(message, total)
+	return p
+}
+
+func (p *Pool) newReporter(message string, total int64) *Reporter {
+	r := New(p.w, p.mode, message, total)
+	r.pool = p
+	return r
+}
+
+// Overall returns the reporter on the last line
+func (p *Pool) Overall() *Reporter {
+	return p.overall
+}
+
+// Add adds a reporter above the overall one, Finish removes it
+func (p *Pool) Add(message string, total int64) *Reporter {
+	r := p.newReporter(message, total)
+	p.mu.Lock()
+	p.reporters = append(p.reporters, r)
+	p.mu.Unlock()
+	return r
+}
+
+// remove writes the final state of r in plain mode, on a terminal its line
+// goes away
+func (p *Pool) remove(r *Reporter) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i, other := range p.reporters {
+		if other == r {
+			p.reporters = append(p.reporters[:i], p.reporters[i+1:]...)
+			break
+		}
+	}
+
+	switch {
+	case p.mode == Plain:
+		r.mu.Lock()
+		r.drawPlain(time.Now())
+		r.mu.Unlock()
+	case p.mode == Terminal && p.stop != nil:
+		p.render(false)
+	}
+}
+
+// Start starts drawing, until Finish is called or ctx is done
+func (p *Pool) Start(ctx context.Context) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.stop != nil || p.mode == Quiet {
+		return
+	}
+	p.stop = make(chan struct{})
+	p.done = make(chan struct{})
+	go p.run(ctx, p.stop, p.done)
+}
+
+func (p *Pool) run(ctx context.Context, stop, done chan struct{}) {
+	defer close(done)
+	every := refresh
+	if p.mode == Plain {
+		every = PlainInterval
+	}
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+
+	for {
+		p.mu.Lock()
+		p.render(false)
+		p.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Finish stops drawing, leaving only the final state of the overall reporter
+func (p *Pool) Finish() {
+	p.mu.Lock()
+	stop, done := p.stop, p.done
+	p.stop, p.done = nil, nil
+	p.mu.Unlock()
+
+	// Wait outside the lock, the goroutine needs it to exit
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.render(true)
+	p.reporters = nil
+	p.lines = 0
+}
+
+// render draws the reporters, only the overall one if final is set. p.mu
+// must be held.
+func (p *Pool) render(final bool) {
+	reporters := []*Reporter{p.overall}
+	if !final {
+		reporters = append(append([]*Reporter{}, p.reporters...), p.overall)
+	}
+
+	now := time.Now()
+	switch p.mode {
+	case Plain:
+		for _, r := range reporters {
+			r.mu.Lock()
+			r.drawPlain(now)
+			r.mu.Unlock()
+		}
+	case Terminal:
+		var buf bytes.Buffer
+		buf.WriteByte('\r')
+		if p.lines > 0 {
+			fmt.Fprintf(&buf, "\x1b[%dA", p.lines)
+		}
+		width := termWidth(p.w)
+		for _, r := range reporters {
+			r.mu.Lock()
+			buf.WriteString(r.line(now, width))
+			r.mu.Unlock()
+			buf.WriteString(clearLine + "\n")
+		}
+		buf.WriteString(clearDown)
+		p.w.Write(buf.Bytes())
+		p.lines = len(reporters)
+	}
+}
@@ -0,0 +1,313 @@
+// Package progress reports the progress of work. On a terminal it draws a
+// bar which is redrawn in place, otherwise it writes a plain line such as
+// "copying 42/100 (42%) 7.0/s eta 6s" every PlainInterval so logs don't fill
+// up. Reader and Writer report the bytes going through them.
+package progress
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mattn/go-runewidth"
+)
+
+var (
+	// PlainInterval is how often a line is written in Plain mode
+	PlainInterval = 5 * time.Second
+
+	refresh   = 100 * time.Millisecond
+	frames    = `|/-\`
+	clearLine = "\x1b[K"
+	maxBar    = 40
+
+	// The rate is sampled every sampleEvery and smoothed with an exponential
+	// moving average, so the ETA doesn't jump around
+	sampleEvery = 500 * time.Millisecond
+	alpha       = 0.2
+)
+
+// Units are what's counted, which changes how counts are shown
+type Units int
+
+const (
+	Count Units = iota
+	Bytes       // shown as KiB, MiB ...
+)
+
+// Reporter reports the progress of work with a total count, which is 0 if
+// unknown. It's safe for concurrent use.
+type Reporter struct {
+	mu      sync.Mutex
+	w       io.Writer
+	mode    Mode
+	units   Units
+	message string
+	total   int64
+	current int64
+	start   time.Time
+	frame   int
+	printed string // last line in plain mode
+	pool    *Pool  // which draws the reporter if set
+
+	rate         float64 // smoothed, per second
+	sampled      time.Time
+	sampledCount int64
+
+	stop chan struct{} // nil when not running
+	done chan struct{} // closed when the goroutine exits
+}
+
+// New returns a reporter writing to w, mode Auto picks the mode from w
+func New(w io.Writer, mode Mode, message string, total int64) *Reporter {
+	now := time.Now()
+	return &Reporter{w: w, mode: mode.Resolve(w), message: message, total: total, start: now, sampled: now}
+}
+
+// Start starts reporting, until Finish is called or ctx is done. It does
+// nothing for a reporter in a Pool, which is drawn by the pool.
+func (r *Reporter) Start(ctx context.Context) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.stop != nil || r.mode == Quiet || r.pool != nil {
+		return
+	}
+	r.stop = make(chan struct{})
+	r.done = make(chan struct{})
+	go r.run(ctx, r.stop, r.done)
+}
+
+func (r *Reporter) run(ctx context.Context, stop, done chan struct{}) {
+	defer close(done)
+	every := refresh
+	if r.mode == Plain {
+		every = PlainInterval
+	}
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+
+	for {
+		r.mu.Lock()
+		r.draw(time.Now())
+		r.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Add adds n to the current count
+func (r *Reporter) Add(n int64) {
+	r.mu.Lock()
+	r.current += n
+	r.sample(time.Now())
+	r.mu.Unlock()
+}
+
+// Write counts the length of p, so a Reporter can be used with io.TeeReader
+// or io.MultiWriter
+func (r *Reporter) Write(p []byte) (int, error) {
+	r.Add(int64(len(p)))
+	return len(p), nil
+}
+
+// Increment adds 1 to the current count
+func (r *Reporter) Increment() {
+	r.Add(1)
+}
+
+// SetTotal changes the total, 0 if it's unknown
+func (r *Reporter) SetTotal(total int64) {
+	r.mu.Lock()
+	r.total = total
+	r.mu.Unlock()
+}
+
+// AddTotal adds n to the total, for when it's found out as work goes on
+func (r *Reporter) AddTotal(n int64) {
+	r.mu.Lock()
+	r.total += n
+	r.mu.Unlock()
+}
+
+// SetUnits changes what's counted
+func (r *Reporter) SetUnits(units Units) {
+	r.mu.Lock()
+	r.units = units
+	r.mu.Unlock()
+}
+
+// SetMessage changes the message shown before the counts
+func (r *Reporter) SetMessage(message string) {
+	r.mu.Lock()
+	r.message = message
+	r.mu.Unlock()
+}
+
+// Finish stops reporting and writes the final counts, a reporter in a Pool
+// is removed from it
+func (r *Reporter) Finish() {
+	if r.pool != nil {
+		r.pool.remove(r)
+		return
+	}
+
+	r.mu.Lock()
+	stop, done := r.stop, r.done
+	r.stop, r.done = nil, nil
+	r.mu.Unlock()
+
+	// Wait outside the lock, the goroutine needs it to exit
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	switch r.mode {
+	case Terminal:
+		r.draw(time.Now())
+		fmt.Fprintln(r.w)
+	case Plain:
+		r.draw(time.Now())
+	}
+}
+
+// sample updates the smoothed rate, r.mu must be held
+func (r *Reporter) sample(now time.Time) {
+	d := now.Sub(r.sampled)
+	if d < sampleEvery {
+		return
+	}
+
+	rate := float64(r.current-r.sampledCount) / d.Seconds()
+	if r.sampledCount == 0 && r.rate == 0 {
+		r.rate = rate
+	} else {
+		r.rate = alpha*rate + (1-alpha)*r.rate
+	}
+	r.sampled, r.sampledCount = now, r.current
+}
+
+func (r *Reporter) format(n float64) string {
+	if r.units == Bytes {
+		return formatBytes(n)
+	}
+	return fmt.Sprintf("%.0f", n)
+}
+
+func (r *Reporter) formatRate() string {
+	if r.units == Bytes {
+		return formatBytes(r.rate) + "/s"
+	}
+	return fmt.Sprintf("%.1f/s", r.rate)
+}
+
+// status formats the counts, e.g. "42/100 (42%) 7.0/s eta 6s", r.mu must be
+// held
+func (r *Reporter) status(now time.Time) string {
+	r.sample(now)
+	current := r.format(float64(r.current))
+	if r.total <= 0 {
+		return current + " " + r.formatRate()
+	}
+
+	s := fmt.Sprintf("%s/%s (%d%%)", current, r.format(float64(r.total)), r.current*100/r.total)
+	switch {
+	case r.current >= r.total:
+		s += " in " + now.Sub(r.start).Round(time.Second).String()
+	case r.rate > 0:
+		eta := time.Duration(float64(r.total-r.current) / r.rate * float64(time.Second))
+		s += " " + r.formatRate() + " eta " + eta.Round(time.Second).String()
+	}
+	return s
+}
+
+// formatBytes formats n bytes with binary units, e.g. 1.5 MiB
+func formatBytes(n float64) string {
+	const units = "KMGTPE"
+	if n < 1024 {
+		return fmt.Sprintf("%.0f B", n)
+	}
+	i := -1
+	for n >= 1024 && i < len(units)-1 {
+		n /= 1024
+		i++
+	}
+	return fmt.Sprintf("%.1f %ciB", n, units[i])
+}
+
+// draw writes the current state, r.mu must be held
+func (r *Reporter) draw(now time.Time) {
+	if r.mode == Plain {
+		r.drawPlain(now)
+		return
+	}
+	fmt.Fprintf(r.w, "\r%s%s", r.line(now, termWidth(r.w)), clearLine)
+}
+
+// drawPlain writes a line with the current state, r.mu must be held
+func (r *Reporter) drawPlain(now time.Time) {
+	// Skip repeating the same line, e.g. when nothing happened
+	if line := r.message + " " + r.status(now); line != r.printed {
+		fmt.Fprintln(r.w, line)
+		r.printed = line
+	}
+}
+
+// termWidth is TermWidth with a default
+func termWidth(w io.Writer) int {
+	if width := TermWidth(w); width > 0 {
+		return width
+	}
+	return 80
+}
+
+// line returns the current state as a line narrower than width, with a bar
+// if the total is known. r.mu must be held.
+func (r *Reporter) line(now time.Time, width int) string {
+	status := r.status(now)
+	var line string
+	if r.total > 0 {
+		n := width - runewidth.StringWidth(r.message) - runewidth.StringWidth(status) - 5
+		if n > maxBar {
+			n = maxBar
+		}
+		line = r.message + " "
+		if n >= 10 {
+			line += bar(n, float64(r.current)/float64(r.total)) + " "
+		}
+		line += status
+	} else {
+		line = fmt.Sprintf("%s %c %s", r.message, frames[r.frame], status)
+		r.frame = (r.frame + 1) % len(frames)
+	}
+
+	if runewidth.StringWidth(line) >= width {
+		line = runewidth.Truncate(line, width-1, "…")
+	}
+	return line
+}
+
+// bar returns a bar n wide including the brackets, frac of it filled
+func bar(n int, frac float64) string {
+	n -= 2
+	if frac > 1 {
+		frac = 1
+	}
+	filled := int(frac * float64(n))
+	s := strings.Repeat("=", filled)
+	if filled < n {
+		s += ">" + strings.Repeat(" ", n-filled-1)
+	}
+	return "[" + s + "]"
+}
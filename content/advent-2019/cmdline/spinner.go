@@ -1,44 +1,73 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
+	"log"
+	"math/rand"
 	"os"
+	"strings"
+	"sync"
 	"time"
-)
-
-var spinChars = `|/-\`
-
-type Spinner struct {
-	message string
-	i       int
-}
 
-func NewSpinner(message string) *Spinner {
-	return &Spinner{message: message}
-}
+	"advent2019/progress"
+	"advent2019/spinner"
+)
 
-func (s *Spinner) Tick() {
-	fmt.Printf("%s %c \r", s.message, spinChars[s.i])
-	s.i = (s.i + 1) % len(spinChars)
-}
+func main() {
+	var (
+		jobs   int
+		frames string
+		mode   progress.Mode
+	)
+	flag.IntVar(&jobs, "jobs", 1, "number of jobs to run in parallel, each with a spinner")
+	flag.StringVar(&frames, "frames", "line", fmt.Sprintf("spinner frames (%s)", strings.Join(spinner.FrameSetNames(), ", ")))
+	flag.Var(&mode, "progress", progress.ModeUsage)
+	flag.Parse()
 
-func isTTY() bool {
-	fi, err := os.Stdout.Stat()
-	if err != nil {
-		return false
+	fs, ok := spinner.FrameSets[frames]
+	if !ok {
+		log.Fatalf("error: unknown frames - %s", frames)
 	}
-	return fi.Mode()&os.ModeCharDevice != 0
-}
 
-func main() {
-	flag.Parse()
-	s := NewSpinner("working...")
-	for i := 0; i < 100; i++ {
-		if isTTY() {
-			s.Tick()
+	if jobs <= 1 {
+		s := spinner.New(os.Stdout, "working...")
+		s.SetFrames(fs)
+		s.SetMode(mode)
+		s.Start(context.Background())
+		for i := 0; i < 100; i++ {
+			if i == 50 {
+				s.UpdateMessage("still working...")
+			}
+			time.Sleep(100 * time.Millisecond)
 		}
-		time.Sleep(100 * time.Millisecond)
+		s.Success("done")
+		return
 	}
 
+	g := spinner.NewGroup(os.Stdout)
+	g.SetMode(mode)
+	var wg sync.WaitGroup
+	for i := 1; i <= jobs; i++ {
+		s := g.Add(fmt.Sprintf("job %d: starting", i))
+		s.SetFrames(fs)
+		wg.Add(1)
+		go func(i int, s *spinner.Spinner) {
+			defer wg.Done()
+			steps := 10 + rand.Intn(30)
+			for step := 1; step <= steps; step++ {
+				s.UpdateMessage(fmt.Sprintf("job %d: step %d of %d", i, step, steps))
+				time.Sleep(100 * time.Millisecond)
+			}
+			if i%4 == 0 {
+				s.Failure(fmt.Sprintf("job %d: failed", i))
+				return
+			}
+			s.Success(fmt.Sprintf("job %d: done", i))
+		}(i, s)
+	}
+	g.Start(context.Background())
+	wg.Wait()
+	g.Stop()
 }
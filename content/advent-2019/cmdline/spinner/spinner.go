@@ -0,0 +1,346 @@
+// Package spinner shows spinners next to messages while work is going on.
+// Spinners animate on their own goroutine when writing to a terminal,
+// otherwise messages are written as plain lines (see progress.Mode). A Group
+// shows a line per spinner, for jobs running in parallel.
+package spinner
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mattn/go-runewidth"
+
+	"advent2019/progress"
+)
+
+const (
+	interval = 100 * time.Millisecond
+
+	clearLine   = "\x1b[K"
+	clearDown   = "\x1b[J"
+	successMark = "✓"
+	failureMark = "✗"
+	runningMark = "-" // in plain mode
+
+	green = "\x1b[32m"
+	red   = "\x1b[31m"
+	reset = "\x1b[0m"
+)
+
+// Frames are the animation frames of a spinner
+type Frames []string
+
+var (
+	Line   = Frames{"|", "/", "-", `\`}
+	Dots   = Frames{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
+	Arrows = Frames{"←", "↖", "↑", "↗", "→", "↘", "↓", "↙"}
+)
+
+// FrameSets are the frame sets by name, for flags
+var FrameSets = map[string]Frames{
+	"line":   Line,
+	"dots":   Dots,
+	"arrows": Arrows,
+}
+
+// FrameSetNames returns the names in FrameSets, sorted
+func FrameSetNames() []string {
+	names := make([]string, 0, len(FrameSets))
+	for name := range FrameSets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Group draws its spinners together, a line each, moving the cursor up to
+// redraw them. It's safe for concurrent use.
+type Group struct {
+	mu       sync.Mutex
+	w        io.Writer
+	mode     progress.Mode
+	color    bool
+	spinners []*Spinner
+	lines    int // drawn last time
+
+	stop chan struct{} // nil when not running
+	done chan struct{} // closed when the goroutine exits
+}
+
+// NewGroup returns a group writing to w, which is not animated unless w is a
+// terminal
+func NewGroup(w io.Writer) *Group {
+	g := &Group{w: w}
+	g.SetMode(progress.Auto)
+	return g
+}
+
+// SetMode changes how the spinners are shown, progress.Auto picks the mode
+// from the output
+func (g *Group) SetMode(mode progress.Mode) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.mode = mode.Resolve(g.w)
+	g.color = g.mode == progress.Terminal && progress.Color()
+}
+
+// Add adds a spinner with message as a new line at the bottom
+func (g *Group) Add(message string) *Spinner {
+	s := &Spinner{g: g, message: message, frames: Line}
+	g.mu.Lock()
+	g.spinners = append(g.spinners, s)
+	g.render(false)
+	g.mu.Unlock()
+	return s
+}
+
+// Start starts the animation, which runs until Stop is called or ctx is done.
+// Starting a running group does nothing.
+func (g *Group) Start(ctx context.Context) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.stop != nil {
+		return
+	}
+
+	g.stop = make(chan struct{})
+	g.done = make(chan struct{})
+	go g.run(ctx, g.stop, g.done)
+}
+
+func (g *Group) run(ctx context.Context, stop, done chan struct{}) {
+	defer close(done)
+	every := interval
+	if g.mode == progress.Plain {
+		every = progress.PlainInterval
+	}
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+
+	for {
+		g.mu.Lock()
+		g.render(false)
+		g.printChanged()
+		for _, s := range g.spinners {
+			s.i = (s.i + 1) % len(s.frames)
+		}
+		g.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			g.mu.Lock()
+			if g.stop == stop {
+				g.stop, g.done = nil, nil
+			}
+			g.finish()
+			g.mu.Unlock()
+			return
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Stop stops the animation. Lines of spinners that haven't succeeded or
+// failed are cleared, the others stay and are no longer part of the group.
+func (g *Group) Stop() {
+	g.mu.Lock()
+	stop, done := g.stop, g.done
+	g.stop, g.done = nil, nil
+	g.mu.Unlock()
+
+	// Wait outside the lock, the goroutine needs it to exit
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+
+	g.mu.Lock()
+	g.finish()
+	g.mu.Unlock()
+}
+
+// printChanged writes a line for running spinners with a new message in
+// plain mode. It, finish and render must be called with g.mu held.
+func (g *Group) printChanged() {
+	if g.mode != progress.Plain {
+		return
+	}
+	for _, s := range g.spinners {
+		if s.mark == "" && s.message != s.printed {
+			fmt.Fprintf(g.w, "%s %s\n", runningMark, s.message)
+			s.printed = s.message
+		}
+	}
+}
+
+func (g *Group) finish() {
+	g.render(true)
+	g.spinners = nil
+	g.lines = 0
+}
+
+// render redraws all lines, only the finished ones if final is set. Lines
+// are cut to the terminal width since a wrapped line throws off moving the
+// cursor up.
+func (g *Group) render(final bool) {
+	if g.mode != progress.Terminal || (g.stop == nil && !final) {
+		return
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte('\r')
+	if g.lines > 0 {
+		fmt.Fprintf(&buf, "\x1b[%dA", g.lines)
+	}
+
+	width := progress.TermWidth(g.w)
+	lines := 0
+	for _, s := range g.spinners {
+		if final && s.mark == "" {
+			continue
+		}
+		prefix := s.prefix()
+		message := s.message
+		// Keep lines narrower than the terminal, full lines wrap on some
+		if room := width - runewidth.StringWidth(prefix) - 2; width > 0 && runewidth.StringWidth(message) > room {
+			message = runewidth.Truncate(message, room, "…")
+		}
+		if g.color {
+			prefix = colorize(s.mark, prefix)
+		}
+		buf.WriteString(prefix + " " + message)
+		buf.WriteString(clearLine + "\n")
+		lines++
+	}
+	buf.WriteString(clearDown)
+
+	g.w.Write(buf.Bytes())
+	g.lines = lines
+}
+
+// Spinner is a single spinner, either on its own from New or in a Group.
+// It's safe for concurrent use.
+type Spinner struct {
+	g       *Group
+	own     bool // g was made by New for this spinner alone
+	message string
+	printed string // last message written in plain mode
+	frames  Frames
+	i       int
+	mark    string // set when finished
+}
+
+// New returns a spinner writing to w, which is not animated unless w is a
+// terminal
+func New(w io.Writer, message string) *Spinner {
+	s := &Spinner{g: NewGroup(w), own: true, message: message, frames: Line}
+	s.g.spinners = []*Spinner{s}
+	return s
+}
+
+// prefix is the current frame or the final mark, s.g.mu must be held
+func (s *Spinner) prefix() string {
+	if s.mark != "" {
+		return s.mark
+	}
+	return s.frames[s.i]
+}
+
+func colorize(mark, prefix string) string {
+	switch mark {
+	case successMark:
+		return green + prefix + reset
+	case failureMark:
+		return red + prefix + reset
+	}
+	return prefix
+}
+
+// SetMode changes how the spinner is shown, for a spinner in a Group it
+// changes the whole group
+func (s *Spinner) SetMode(mode progress.Mode) {
+	s.g.SetMode(mode)
+}
+
+// SetFrames changes the animation frames
+func (s *Spinner) SetFrames(frames Frames) {
+	if len(frames) == 0 {
+		return
+	}
+	s.g.mu.Lock()
+	defer s.g.mu.Unlock()
+	s.frames = frames
+	s.i = 0
+}
+
+// Start starts the animation, of the whole group for a spinner in a Group
+func (s *Spinner) Start(ctx context.Context) {
+	if s.own {
+		s.g.mu.Lock()
+		if len(s.g.spinners) == 0 {
+			s.mark = ""
+			s.g.spinners = append(s.g.spinners, s)
+		}
+		s.g.mu.Unlock()
+	}
+	s.g.Start(ctx)
+}
+
+// UpdateMessage changes the message shown next to the spinner
+func (s *Spinner) UpdateMessage(message string) {
+	s.g.mu.Lock()
+	defer s.g.mu.Unlock()
+	s.message = message
+	s.g.render(false)
+}
+
+// Stop stops the spinner and clears its line
+func (s *Spinner) Stop() {
+	s.finish("", "")
+}
+
+// Success stops the spinner and shows message (the current one if empty)
+// marked as successful
+func (s *Spinner) Success(message string) {
+	s.finish(successMark, message)
+}
+
+// Failure stops the spinner and shows message (the current one if empty)
+// marked as failed
+func (s *Spinner) Failure(message string) {
+	s.finish(failureMark, message)
+}
+
+func (s *Spinner) finish(mark, message string) {
+	g := s.g
+	g.mu.Lock()
+	if message != "" {
+		s.message = message
+	}
+	s.mark = mark
+	if mark == "" {
+		for i, other := range g.spinners {
+			if other == s {
+				g.spinners = append(g.spinners[:i], g.spinners[i+1:]...)
+				break
+			}
+		}
+	}
+	// Without a terminal there's no redrawing, print the result right away
+	if g.mode == progress.Plain && mark != "" {
+		fmt.Fprintf(g.w, "%s %s\n", mark, s.message)
+	}
+	g.render(false)
+	g.mu.Unlock()
+
+	if s.own {
+		g.Stop()
+	}
+}
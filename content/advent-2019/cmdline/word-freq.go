@@ -2,65 +2,2131 @@ package main
 
 import (
 	"bufio"
+	"container/heap"
+	"encoding/csv"
+	"encoding/json"
 	"flag"
 	"fmt"
+	"hash/fnv"
 	"io"
 	"log"
+	"math"
 	"os"
+	"path/filepath"
 	"regexp"
+	"runtime"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
 )
 
+var config struct {
+	tokenizer Tokenizer
+	fold      bool
+	stopWords map[string]bool
+	stem      bool
+	ngram     int  // count sequences of ngram terms
+	unigrams  bool // also count single terms when ngram > 1
+	filter    func() lineFilter
+}
+
+// Tokenizer splits a line of text into words
+type Tokenizer interface {
+	Tokenize(line string) []string
+}
+
+// UnicodeTokenizer splits text on anything that is not a Unicode letter,
+// number or combining mark. Han ideographs are emitted one per token since
+// Chinese and Japanese text has no spaces between words.
+type UnicodeTokenizer struct {
+	Apostrophes bool // keep "don't" as a single word
+	Hyphens     bool // keep "well-known" as a single word
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsNumber(r) || unicode.Is(unicode.Mn, r)
+}
+
+func (t *UnicodeTokenizer) joiner(r rune) bool {
+	switch r {
+	case '\'', '’':
+		return t.Apostrophes
+	case '-', '‐':
+		return t.Hyphens
+	}
+	return false
+}
+
+func (t *UnicodeTokenizer) Tokenize(line string) []string {
+	var words []string
+	start := -1
+	for i, r := range line {
+		switch {
+		case unicode.Is(unicode.Han, r):
+			if start >= 0 {
+				words = append(words, line[start:i])
+				start = -1
+			}
+			words = append(words, string(r))
+		case isWordRune(r):
+			if start < 0 {
+				start = i
+			}
+		case start >= 0 && t.joiner(r):
+			// Joiners only count between two word characters
+			next, _ := utf8.DecodeRuneInString(line[i+utf8.RuneLen(r):])
+			if !isWordRune(next) || unicode.Is(unicode.Han, next) {
+				words = append(words, line[start:i])
+				start = -1
+			}
+		case start >= 0:
+			words = append(words, line[start:i])
+			start = -1
+		}
+	}
+	if start >= 0 {
+		words = append(words, line[start:])
+	}
+	return words
+}
+
+// RegexpTokenizer returns every match of a regular expression as a word
+type RegexpTokenizer struct {
+	re *regexp.Regexp
+}
+
+func NewRegexpTokenizer(expr string) (*RegexpTokenizer, error) {
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	return &RegexpTokenizer{re}, nil
+}
+
+func (t *RegexpTokenizer) Tokenize(line string) []string {
+	return t.re.FindAllString(line, -1)
+}
+
+// foldCase maps every rune to its lower case form. Going through upper case
+// first folds variants such as final sigma (ς) and long s (ſ) as well.
+func foldCase(s string) string {
+	return strings.Map(func(r rune) rune {
+		return unicode.ToLower(unicode.ToUpper(r))
+	}, s)
+}
+
+// Built-in stop word lists, keyed by ISO 639-1 language code
+var stopLists = map[string]string{
+	"en": `a about above after again against all am an and any are as at be
+because been before being below between both but by can could did do does
+doing don't down during each few for from further had has have having he her
+here hers herself him himself his how i if in into is it it's its itself just
+me more most my myself no nor not now of off on once only or other our ours
+ourselves out over own same she should so some such than that the their
+theirs them themselves then there these they this those through to too under
+until up very was we were what when where which while who whom why will with
+would you your yours yourself yourselves`,
+	"de": `aber alle allem allen aller alles als also am an ander andere anderem
+anderen anderer anderes auch auf aus bei bin bis bist da damit dann das dass
+dein deine dem den der des dich die dies diese diesem diesen dieser dieses dir
+doch dort du durch ein eine einem einen einer eines er es euer eure für hat
+hatte hier hin ich ihr ihre im in ist ja jede jedem jeden jeder jedes kein
+keine man mein meine mich mir mit nach nicht noch nun nur ob oder ohne sehr
+sein seine sich sie sind so über um und uns unser unter vom von vor war waren
+was weil wenn wer wie wir wird wo zu zum zur`,
+	"fr": `à au aux avec ce ces c'est dans de des du elle en et eux il ils je
+la le les leur lui ma mais me même mes moi mon ne nos notre nous on ou où par
+pas pour qu que qui sa se ses son sur ta te tes toi ton tu un une vos votre
+vous été être avoir est sont était ont a été l d j n s y`,
+	"es": `a al algo algunos ante antes como con contra cual cuando de del desde
+donde durante e el ella ellas ellos en entre era es esa esas ese eso esos esta
+estas este esto estos fue ha hay la las le les lo los más me mi muy nada ni no
+nos o os otra otro para pero poco por porque que quien se sea ser si sin sobre
+su sus también te tiene todo tu un una uno unos y ya yo`,
+	"he": `אבל או אז אחר אחרי אל אם אני אנחנו את אתה אתם בין גם הוא היא היה
+הם הן הנה זה זאת זו יש כי כל כמו כן לא לי מה מי מן עד על עם של שלא שם`,
+}
+
+func stopLanguages() []string {
+	langs := make([]string, 0, len(stopLists))
+	for lang := range stopLists {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	return langs
+}
+
+// loadStopWords builds the stop word set from a comma separated list of
+// built-in languages and an optional file with one word per line (lines
+// starting with # are ignored)
+func loadStopWords(langs, path string) (map[string]bool, error) {
+	stop := make(map[string]bool)
+	add := func(w string) {
+		if config.fold {
+			w = foldCase(w)
+		}
+		stop[w] = true
+	}
+
+	for _, lang := range strings.Split(langs, ",") {
+		lang = strings.TrimSpace(lang)
+		if lang == "" {
+			continue
+		}
+		words, ok := stopLists[lang]
+		if !ok {
+			return nil, fmt.Errorf("unknown stop words language - %s", lang)
+		}
+		for _, w := range strings.Fields(words) {
+			add(w)
+		}
+	}
+
+	if path == "" {
+		return stop, nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	s := bufio.NewScanner(file)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		add(line)
+	}
+	return stop, s.Err()
+}
+
+// porterStem returns the stem of an English word using the Porter (1980)
+// algorithm. Words with anything other than lower case ASCII letters are
+// returned as is.
+func porterStem(w string) string {
+	if len(w) <= 2 {
+		return w
+	}
+	for i := 0; i < len(w); i++ {
+		if w[i] < 'a' || w[i] > 'z' {
+			return w
+		}
+	}
+
+	p := &porter{b: []byte(w), k: len(w) - 1}
+	p.step1ab()
+	if p.k > 0 {
+		p.step1c()
+		p.step2()
+		p.step3()
+		p.step4()
+		p.step5()
+	}
+	return string(p.b[:p.k+1])
+}
+
+// porter holds the stemmer state, b[0:k+1] is the current word and j marks
+// the end of the stem found by the last successful ends
+type porter struct {
+	b    []byte
+	k, j int
+}
+
+func (p *porter) cons(i int) bool {
+	switch p.b[i] {
+	case 'a', 'e', 'i', 'o', 'u':
+		return false
+	case 'y':
+		return i == 0 || !p.cons(i-1)
+	}
+	return true
+}
+
+// m measures the number of consonant-vowel sequences in b[0:j+1]
+func (p *porter) m() int {
+	n, i := 0, 0
+	for ; ; i++ {
+		if i > p.j {
+			return n
+		}
+		if !p.cons(i) {
+			break
+		}
+	}
+	i++
+	for {
+		for ; ; i++ {
+			if i > p.j {
+				return n
+			}
+			if p.cons(i) {
+				break
+			}
+		}
+		i++
+		n++
+		for ; ; i++ {
+			if i > p.j {
+				return n
+			}
+			if !p.cons(i) {
+				break
+			}
+		}
+		i++
+	}
+}
+
+func (p *porter) vowelInStem() bool {
+	for i := 0; i <= p.j; i++ {
+		if !p.cons(i) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *porter) doubleC(j int) bool {
+	return j >= 1 && p.b[j] == p.b[j-1] && p.cons(j)
+}
+
+// cvc reports if b[i-2:i+1] is consonant-vowel-consonant and the last
+// consonant is not w, x or y
+func (p *porter) cvc(i int) bool {
+	if i < 2 || !p.cons(i) || p.cons(i-1) || !p.cons(i-2) {
+		return false
+	}
+	switch p.b[i] {
+	case 'w', 'x', 'y':
+		return false
+	}
+	return true
+}
+
+func (p *porter) ends(s string) bool {
+	l := len(s)
+	if l > p.k+1 || string(p.b[p.k-l+1:p.k+1]) != s {
+		return false
+	}
+	p.j = p.k - l
+	return true
+}
+
+func (p *porter) setTo(s string) {
+	p.b = append(p.b[:p.j+1], s...)
+	p.k = p.j + len(s)
+}
+
+func (p *porter) replace(s string) {
+	if p.m() > 0 {
+		p.setTo(s)
+	}
+}
+
+// step1ab removes plurals and -ed or -ing
+func (p *porter) step1ab() {
+	if p.b[p.k] == 's' {
+		switch {
+		case p.ends("sses"):
+			p.k -= 2
+		case p.ends("ies"):
+			p.setTo("i")
+		case p.b[p.k-1] != 's':
+			p.k--
+		}
+	}
+
+	if p.ends("eed") {
+		if p.m() > 0 {
+			p.k--
+		}
+		return
+	}
+	if (p.ends("ed") || p.ends("ing")) && p.vowelInStem() {
+		p.k = p.j
+		switch {
+		case p.ends("at"):
+			p.setTo("ate")
+		case p.ends("bl"):
+			p.setTo("ble")
+		case p.ends("iz"):
+			p.setTo("ize")
+		case p.doubleC(p.k):
+			p.k--
+			switch p.b[p.k] {
+			case 'l', 's', 'z':
+				p.k++
+			}
+		case p.m() == 1 && p.cvc(p.k):
+			p.setTo("e")
+		}
+	}
+}
+
+// step1c turns terminal y to i when there is another vowel in the stem
+func (p *porter) step1c() {
+	if p.ends("y") && p.vowelInStem() {
+		p.b[p.k] = 'i'
+	}
+}
+
+// replaceSuffix replaces the first matching suffix in pairs (suffix,
+// replacement, ...) if the stem measure is positive
+func (p *porter) replaceSuffix(pairs ...string) {
+	for i := 0; i < len(pairs); i += 2 {
+		if p.ends(pairs[i]) {
+			p.replace(pairs[i+1])
+			return
+		}
+	}
+}
+
+// step2 maps double suffixes to single ones (-ization -> -ize)
+func (p *porter) step2() {
+	switch p.b[p.k-1] {
+	case 'a':
+		p.replaceSuffix("ational", "ate", "tional", "tion")
+	case 'c':
+		p.replaceSuffix("enci", "ence", "anci", "ance")
+	case 'e':
+		p.replaceSuffix("izer", "ize")
+	case 'l':
+		p.replaceSuffix("bli", "ble", "alli", "al", "entli", "ent", "eli", "e", "ousli", "ous")
+	case 'o':
+		p.replaceSuffix("ization", "ize", "ation", "ate", "ator", "ate")
+	case 's':
+		p.replaceSuffix("alism", "al", "iveness", "ive", "fulness", "ful", "ousness", "ous")
+	case 't':
+		p.replaceSuffix("aliti", "al", "iviti", "ive", "biliti", "ble")
+	case 'g':
+		p.replaceSuffix("logi", "log")
+	}
+}
+
+// step3 handles -ic-, -full, -ness etc.
+func (p *porter) step3() {
+	switch p.b[p.k] {
+	case 'e':
+		p.replaceSuffix("icate", "ic", "ative", "", "alize", "al")
+	case 'i':
+		p.replaceSuffix("iciti", "ic")
+	case 'l':
+		p.replaceSuffix("ical", "ic", "ful", "")
+	case 's':
+		p.replaceSuffix("ness", "")
+	}
+}
+
+// step4 removes -ant, -ence etc. in context <c>vcvc<v>
+func (p *porter) step4() {
+	var suffixes []string
+	switch p.b[p.k-1] {
+	case 'a':
+		suffixes = []string{"al"}
+	case 'c':
+		suffixes = []string{"ance", "ence"}
+	case 'e':
+		suffixes = []string{"er"}
+	case 'i':
+		suffixes = []string{"ic"}
+	case 'l':
+		suffixes = []string{"able", "ible"}
+	case 'n':
+		suffixes = []string{"ant", "ement", "ment", "ent"}
+	case 'o':
+		if p.ends("ion") && p.j >= 0 && (p.b[p.j] == 's' || p.b[p.j] == 't') {
+			break
+		}
+		suffixes = []string{"ou"}
+	case 's':
+		suffixes = []string{"ism"}
+	case 't':
+		suffixes = []string{"ate", "iti"}
+	case 'u':
+		suffixes = []string{"ous"}
+	case 'v':
+		suffixes = []string{"ive"}
+	case 'z':
+		suffixes = []string{"ize"}
+	default:
+		return
+	}
+
+	matched := suffixes == nil // the -sion/-tion case above
+	for _, s := range suffixes {
+		if p.ends(s) {
+			matched = true
+			break
+		}
+	}
+	if matched && p.m() > 1 {
+		p.k = p.j
+	}
+}
+
+// step5 removes a final -e and changes -ll to -l if m() > 1
+func (p *porter) step5() {
+	p.j = p.k
+	if p.b[p.k] == 'e' {
+		a := p.m()
+		if a > 1 || a == 1 && !p.cvc(p.k-1) {
+			p.k--
+		}
+	}
+	if p.b[p.k] == 'l' && p.doubleC(p.k) && p.m() > 1 {
+		p.k--
+	}
+}
+
+// counter accumulates term counts. wordCounts is exact, sketch trades
+// accuracy for bounded memory.
+type counter interface {
+	add(term string)
+}
+
+type wordCounts map[string]int
+
+func (c wordCounts) add(term string) {
+	c[term]++
+}
+
+func wordFreq(r io.Reader) (map[string]int, error) {
+	counts := make(map[string]int)
+	err := countWords(r, wordCounts(counts))
+	return counts, err
+}
+
+// normalize returns the term to count for w, ok is false if w should be
+// dropped
+func normalize(w string) (term string, ok bool) {
+	if config.fold {
+		w = foldCase(w)
+	}
+	if config.stopWords[w] {
+		return "", false
+	}
+	if config.stem {
+		w = porterStem(w)
+	}
+	return w, true
+}
+
+// lineFilter removes markup from the lines of a document before they are
+// tokenized. Filters keep state between lines (e.g. inside a code fence), so
+// every document gets a new one.
+type lineFilter interface {
+	Filter(line string) string
+}
+
 var (
-	wordRe = regexp.MustCompile(`[a-zA-Z]+`)
+	fenceRe          = regexp.MustCompile("^\\s*(`{3,}|~{3,})")
+	indentedRe       = regexp.MustCompile(`^( {4}|\t)`)
+	highlightStartRe = regexp.MustCompile(`^\s*\{\{[<%]\s*highlight\b`)
+	highlightEndRe   = regexp.MustCompile(`\{\{[<%]\s*/highlight\s*[%>]\}\}`)
+	refDefRe         = regexp.MustCompile(`^\s*\[[^\]]+\]:\s*\S+`)
+	inlineCodeRe     = regexp.MustCompile("``[^`]+``|`[^`]+`")
+	commentRe        = regexp.MustCompile(`<!--.*?-->`)
+	markupRe         = regexp.MustCompile(strings.Join([]string{
+		`\{\{[<%].*?[%>]\}\}`, // Hugo shortcode
+		`\]\([^)]*\)`,         // link target, the link text is kept
+		`https?://\S+`,        // bare URL
+		`<[^>]*>`,             // HTML tag
+		`&#?\w+;`,             // HTML entity
+	}, "|"))
+	identRe = `[\p{L}_][\p{L}\p{N}_]*`
+)
+
+// markdownFilter keeps only the prose of Markdown (and HTML) blog posts,
+// dropping front matter, code, Hugo shortcodes and markup. With code set it
+// does the opposite and keeps only fenced, indented, highlighted and inline
+// code.
+type markdownFilter struct {
+	code        bool
+	lineNo      int
+	frontMatter string // closing +++ or --- while in front matter
+	fence       string // opening ``` or ~~~ while in a code fence
+	highlight   bool   // inside {{< highlight >}}
+	comment     bool   // inside <!-- -->
+	tag         bool   // inside an HTML tag, e.g. <svg ...> over several lines
+	blank       bool   // the previous line was blank
+	indented    bool   // inside an indented code block
+}
+
+func (f *markdownFilter) Filter(line string) string {
+	first := f.lineNo == 0
+	f.lineNo++
+	trimmed := strings.TrimSpace(line)
+	blank := f.blank || first
+	f.blank = trimmed == ""
+
+	switch {
+	case f.frontMatter != "":
+		if trimmed == f.frontMatter {
+			f.frontMatter = ""
+		}
+		return ""
+	case first && (trimmed == "+++" || trimmed == "---"):
+		f.frontMatter = trimmed
+		return ""
+	case f.fence != "":
+		if strings.HasPrefix(trimmed, f.fence) && strings.Trim(trimmed, f.fence[:1]) == "" {
+			f.fence = ""
+			return ""
+		}
+		return f.codeLine(line)
+	case fenceRe.MatchString(line):
+		f.fence = fenceRe.FindStringSubmatch(line)[1]
+		return ""
+	case f.highlight:
+		if highlightEndRe.MatchString(line) {
+			f.highlight = false
+			return ""
+		}
+		return f.codeLine(line)
+	case highlightStartRe.MatchString(line):
+		f.highlight = !highlightEndRe.MatchString(line)
+		return ""
+	case trimmed == "" || f.comment:
+		return f.prose(line)
+	}
+
+	// An indented code block starts after a blank line, it can't interrupt a
+	// paragraph, and goes on until a line which isn't indented
+	if (blank || f.indented) && indentedRe.MatchString(line) {
+		f.indented = true
+		return f.codeLine(line)
+	}
+	f.indented = false
+	return f.prose(line)
+}
+
+func (f *markdownFilter) codeLine(line string) string {
+	if f.code {
+		return line
+	}
+	return ""
+}
+
+func (f *markdownFilter) prose(line string) string {
+	if f.comment {
+		i := strings.Index(line, "-->")
+		if i < 0 {
+			return ""
+		}
+		line, f.comment = line[i+len("-->"):], false
+	}
+	line = commentRe.ReplaceAllString(line, " ")
+	if i := strings.Index(line, "<!--"); i >= 0 {
+		line, f.comment = line[:i], true
+	}
+
+	// Attributes of a tag split over lines aren't prose
+	if f.tag {
+		i := strings.Index(line, ">")
+		if i < 0 {
+			return ""
+		}
+		line, f.tag = line[i+1:], false
+	}
+	if i := openTag(line); i >= 0 {
+		line, f.tag = line[:i], true
+	}
+
+	if refDefRe.MatchString(line) {
+		return ""
+	}
+	if f.code {
+		return strings.Join(inlineCodeRe.FindAllString(line, -1), " ")
+	}
+	line = inlineCodeRe.ReplaceAllString(line, " ")
+	return markupRe.ReplaceAllString(line, " ")
+}
+
+// openTag returns where a tag that isn't closed on line starts, -1 if there's
+// none. Inline code is skipped, a < in there is no tag.
+func openTag(line string) int {
+	masked := inlineCodeRe.ReplaceAllStringFunc(line, func(code string) string {
+		return strings.Repeat(" ", len(code))
+	})
+	i := strings.LastIndex(masked, "<")
+	if i < 0 || i+1 == len(masked) || strings.Contains(masked[i:], ">") {
+		return -1
+	}
+	switch c := masked[i+1]; {
+	case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c == '/', c == '!', c == '?':
+		return i
+	}
+	return -1
+}
+
+// inputFilters are the -input modes, plain text has no filter
+var inputFilters = map[string]func(code bool) lineFilter{
+	"text": nil,
+	"markdown": func(code bool) lineFilter {
+		return &markdownFilter{code: code}
+	},
+}
+
+// countWords adds the n-grams in r to counts
+func countWords(r io.Reader, counts counter) error {
+	t := newTermCounter(counts)
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		t.line(s.Text())
+	}
+	return s.Err()
+}
+
+// termCounter runs lines of a single document through the filter, tokenizer
+// and normalize and adds the resulting n-grams to counts. The n-gram window
+// is carried across lines so a phrase broken by a newline is still counted.
+type termCounter struct {
+	counts counter
+	filter lineFilter
+	n      int
+	window []string
+}
+
+func newTermCounter(counts counter) *termCounter {
+	n := config.ngram
+	if n < 1 {
+		n = 1
+	}
+	t := &termCounter{counts: counts, n: n, window: make([]string, 0, n)}
+	if config.filter != nil {
+		t.filter = config.filter()
+	}
+	return t
+}
+
+func (t *termCounter) line(line string) {
+	if t.filter != nil {
+		line = t.filter.Filter(line)
+	}
+
+	for _, w := range config.tokenizer.Tokenize(line) {
+		w, ok := normalize(w)
+		if !ok {
+			continue
+		}
+
+		if len(t.window) == t.n {
+			copy(t.window, t.window[1:])
+			t.window = t.window[:t.n-1]
+		}
+		t.window = append(t.window, w)
+
+		if len(t.window) == t.n {
+			t.counts.add(strings.Join(t.window, gramSep))
+		}
+		if t.n > 1 && config.unigrams {
+			t.counts.add(w)
+		}
+	}
+}
+
+// gramSep separates the terms of an n-gram in the counts map
+const gramSep = " "
+
+func gramLen(gram string) int {
+	return strings.Count(gram, gramSep) + 1
+}
+
+// pmiScores returns the pointwise mutual information of every n-gram in
+// counts, that is log2(P(w1..wn) / (P(w1)...P(wn))). counts must hold the
+// unigrams as well (see config.unigrams). N-grams seen less than minCount
+// times are skipped since PMI overrates rare events.
+func pmiScores(counts map[string]int, n, minCount int) map[string]float64 {
+	var nGrams, nWords int
+	for gram, c := range counts {
+		switch gramLen(gram) {
+		case 1:
+			nWords += c
+		case n:
+			nGrams += c
+		}
+	}
+
+	scores := make(map[string]float64)
+	for gram, c := range counts {
+		if c < minCount || gramLen(gram) != n {
+			continue
+		}
+		pmi := math.Log2(float64(c) / float64(nGrams))
+		for _, w := range strings.Split(gram, gramSep) {
+			pmi -= math.Log2(float64(counts[w]) / float64(nWords))
+		}
+		scores[gram] = pmi
+	}
+	return scores
+}
+
+// topScores returns the (up to) n keys with the highest score
+func topScores(scores map[string]float64, n int) []string {
+	t := newTopK(n, func(a, b string) bool {
+		if scores[a] != scores[b] {
+			return scores[a] > scores[b]
+		}
+		return a < b
+	})
+	for gram := range scores {
+		t.offer(gram)
+	}
+	return t.sorted()
+}
+
+func countFile(path string, counts counter) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if err := countWords(file, counts); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// walkFiles sends every regular file under paths to out, then closes it.
+// Paths given are followed if they're symlinks, and read even if they aren't
+// regular files (e.g. /dev/stdin).
+func walkFiles(paths []string, out chan<- string) error {
+	defer close(out)
+
+	for _, root := range paths {
+		info, err := os.Stat(root)
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			out <- root
+			continue
+		}
+		// WalkDir doesn't follow a symlink root, unless it ends with a separator
+		if !strings.HasSuffix(root, string(filepath.Separator)) {
+			root += string(filepath.Separator)
+		}
+
+		err = filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.Type().IsRegular() {
+				out <- path
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// filesFreq counts words in all files under paths using up to workers
+// goroutines and returns the merged counts
+func filesFreq(paths []string, workers int) (map[string]int, error) {
+	if workers < 1 {
+		workers = 1
+	}
+	shards := make([]counter, workers)
+	for i := range shards {
+		shards[i] = wordCounts(make(map[string]int))
+	}
+	if err := countFiles(paths, shards); err != nil {
+		return nil, err
+	}
+
+	counts := shards[0].(wordCounts)
+	for _, shard := range shards[1:] {
+		mergeCounts(counts, shard.(wordCounts))
+	}
+	return counts, nil
+}
+
+// countFiles counts words in all files under paths with one goroutine per
+// shard. Each worker counts into its own shard, the caller merges them at the
+// end so there's no locking on the hot path.
+func countFiles(paths []string, shards []counter) error {
+	pathCh := make(chan string)
+	errs := make([]error, len(shards))
+	var wg sync.WaitGroup
+
+	for i := range shards {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for path := range pathCh {
+				// Keep draining pathCh after an error so walkFiles won't block
+				if errs[i] != nil {
+					continue
+				}
+				errs[i] = countFile(path, shards[i])
+			}
+		}(i)
+	}
+
+	walkErr := walkFiles(paths, pathCh)
+	wg.Wait()
+
+	if walkErr != nil {
+		return walkErr
+	}
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func mergeCounts(dst, src map[string]int) {
+	for w, c := range src {
+		dst[w] += c
+	}
+}
+
+// topK keeps the n best words offered to it in a min-heap ordered by better,
+// so memory is O(n) regardless of the vocabulary size
+type topK struct {
+	n      int
+	better func(a, b string) bool
+	words  []string
+}
+
+func newTopK(n int, better func(a, b string) bool) *topK {
+	return &topK{n: n, better: better}
+}
+
+func (t *topK) Len() int           { return len(t.words) }
+func (t *topK) Less(i, j int) bool { return t.better(t.words[j], t.words[i]) }
+func (t *topK) Swap(i, j int)      { t.words[i], t.words[j] = t.words[j], t.words[i] }
+func (t *topK) Push(x interface{}) { t.words = append(t.words, x.(string)) }
+
+func (t *topK) Pop() interface{} {
+	last := len(t.words) - 1
+	w := t.words[last]
+	t.words = t.words[:last]
+	return w
+}
+
+func (t *topK) offer(w string) {
+	switch {
+	case t.n <= 0:
+		return
+	case len(t.words) < t.n:
+		heap.Push(t, w)
+	case t.better(w, t.words[0]):
+		t.words[0] = w
+		heap.Fix(t, 0)
+	}
+}
+
+// sorted empties t and returns its words, best first
+func (t *topK) sorted() []string {
+	words := make([]string, len(t.words))
+	for i := len(words) - 1; i >= 0; i-- {
+		words[i] = heap.Pop(t).(string)
+	}
+	return words
+}
+
+// ordering returns a function reporting if word a should be listed before b
+type ordering func(freqs map[string]int) func(a, b string) bool
+
+// orderings are the -sort options. Each is a total order (ties are broken by
+// the word itself) so the output is the same between runs.
+var orderings = map[string]ordering{
+	"count":     byCount,
+	"count-asc": byCountAsc,
+	"word":      byWord,
+}
+
+// byCount orders by count, most frequent first, then by word
+func byCount(freqs map[string]int) func(a, b string) bool {
+	return func(a, b string) bool {
+		if freqs[a] != freqs[b] {
+			return freqs[a] > freqs[b]
+		}
+		return a < b
+	}
+}
+
+// byCountAsc orders by count, least frequent first, then by word
+func byCountAsc(freqs map[string]int) func(a, b string) bool {
+	return func(a, b string) bool {
+		if freqs[a] != freqs[b] {
+			return freqs[a] < freqs[b]
+		}
+		return a < b
+	}
+}
+
+func byWord(freqs map[string]int) func(a, b string) bool {
+	return func(a, b string) bool {
+		return a < b
+	}
+}
+
+// topN returns the n most frequent words, ordered by count descending and
+// then by word. It returns all words if there are fewer than n.
+func topN(freqs map[string]int, n int) []string {
+	return topNBy(freqs, n, byCount)
+}
+
+// topNBy returns the first n words of freqs in the given order
+func topNBy(freqs map[string]int, n int, order ordering) []string {
+	t := newTopK(n, order(freqs))
+	for w := range freqs {
+		t.offer(w)
+	}
+	return t.sorted()
+}
+
+// countMinSketch estimates term counts in fixed memory. Estimates never
+// undercount, and overcount by at most e/width*N with probability
+// 1-e^-depth where N is the number of terms added.
+type countMinSketch struct {
+	width uint64
+	rows  [][]uint64
+}
+
+func newCountMinSketch(width, depth int) *countMinSketch {
+	rows := make([][]uint64, depth)
+	for i := range rows {
+		rows[i] = make([]uint64, width)
+	}
+	return &countMinSketch{width: uint64(width), rows: rows}
+}
+
+// cell returns the column of term in row i, using double hashing to derive
+// depth hash functions from one FNV hash
+func (s *countMinSketch) cell(i int, h uint64) uint64 {
+	h1, h2 := h&0xffffffff, h>>32
+	return (h1 + uint64(i)*h2) % s.width
+}
+
+func hashTerm(term string) uint64 {
+	h := fnv.New64a()
+	io.WriteString(h, term)
+	return h.Sum64()
+}
+
+func (s *countMinSketch) add(term string, n uint64) {
+	h := hashTerm(term)
+	for i, row := range s.rows {
+		row[s.cell(i, h)] += n
+	}
+}
+
+func (s *countMinSketch) estimate(term string) uint64 {
+	h := hashTerm(term)
+	est := uint64(math.MaxUint64)
+	for i, row := range s.rows {
+		if c := row[s.cell(i, h)]; c < est {
+			est = c
+		}
+	}
+	return est
+}
+
+// merge adds o to s, both must have the same dimensions
+func (s *countMinSketch) merge(o *countMinSketch) {
+	for i, row := range s.rows {
+		for j, c := range o.rows[i] {
+			row[j] += c
+		}
+	}
+}
+
+// epsilon is the relative error bound of estimate
+func (s *countMinSketch) epsilon() float64 {
+	return math.E / float64(s.width)
+}
+
+// delta is the probability an estimate exceeds the error bound
+func (s *countMinSketch) delta() float64 {
+	return math.Exp(-float64(len(s.rows)))
+}
+
+type ssEntry struct {
+	term  string
+	count uint64
+	err   uint64 // count overestimates the true count by at most err
+	pos   int    // index in spaceSaving.entries
+}
+
+// spaceSaving tracks the k most frequent terms (Metwally et al. 2005). Every
+// term seen more than N/k times is guaranteed to be tracked.
+type spaceSaving struct {
+	k       int
+	entries []*ssEntry // min-heap by count
+	index   map[string]*ssEntry
+}
+
+func newSpaceSaving(k int) *spaceSaving {
+	return &spaceSaving{k: k, index: make(map[string]*ssEntry)}
+}
+
+func (s *spaceSaving) Len() int           { return len(s.entries) }
+func (s *spaceSaving) Less(i, j int) bool { return s.entries[i].count < s.entries[j].count }
+
+func (s *spaceSaving) Swap(i, j int) {
+	s.entries[i], s.entries[j] = s.entries[j], s.entries[i]
+	s.entries[i].pos = i
+	s.entries[j].pos = j
+}
+
+func (s *spaceSaving) Push(x interface{}) {
+	e := x.(*ssEntry)
+	e.pos = len(s.entries)
+	s.entries = append(s.entries, e)
+}
+
+func (s *spaceSaving) Pop() interface{} {
+	last := len(s.entries) - 1
+	e := s.entries[last]
+	s.entries = s.entries[:last]
+	return e
+}
+
+func (s *spaceSaving) add(term string, n uint64) {
+	if e, ok := s.index[term]; ok {
+		e.count += n
+		heap.Fix(s, e.pos)
+		return
+	}
+
+	if len(s.entries) < s.k {
+		e := &ssEntry{term: term, count: n}
+		heap.Push(s, e)
+		s.index[term] = e
+		return
+	}
+
+	// Evict the least frequent term, the newcomer inherits its count as error
+	e := s.entries[0]
+	delete(s.index, e.term)
+	e.term, e.err, e.count = term, e.count, e.count+n
+	s.index[term] = e
+	heap.Fix(s, 0)
+}
+
+// minCount is an upper bound on the count of any term not tracked by s
+func (s *spaceSaving) minCount() uint64 {
+	if len(s.entries) < s.k {
+		return 0
+	}
+	return s.entries[0].count
+}
+
+// merge combines o into s, keeping the error guarantees (Agarwal et al. 2012)
+func (s *spaceSaving) merge(o *spaceSaving) {
+	sMin, oMin := s.minCount(), o.minCount()
+	merged := make([]*ssEntry, 0, len(s.entries)+len(o.entries))
+	for _, e := range s.entries {
+		if oe, ok := o.index[e.term]; ok {
+			e.count += oe.count
+			e.err += oe.err
+		} else {
+			e.count += oMin
+			e.err += oMin
+		}
+		merged = append(merged, e)
+	}
+	for _, oe := range o.entries {
+		if _, ok := s.index[oe.term]; !ok {
+			merged = append(merged, &ssEntry{term: oe.term, count: oe.count + sMin, err: oe.err + sMin})
+		}
+	}
+
+	sort.Slice(merged, func(i, j int) bool { return merged[i].count > merged[j].count })
+	if len(merged) > s.k {
+		merged = merged[:s.k]
+	}
+
+	s.entries = s.entries[:0]
+	s.index = make(map[string]*ssEntry, len(merged))
+	for _, e := range merged {
+		s.Push(e)
+		s.index[e.term] = e
+	}
+	heap.Init(s)
+}
+
+// sketch is an approximate counter using a fixed amount of memory. Space-Saving
+// finds the heavy hitters and Count-Min tightens their counts.
+type sketch struct {
+	cms   *countMinSketch
+	heavy *spaceSaving
+	total uint64
+}
+
+const (
+	sketchDepth  = 5   // Count-Min rows, error probability e^-5 < 1%
+	ssEntryBytes = 128 // rough memory per Space-Saving entry, including the term
 )
 
-func wordFreq(r io.Reader) (map[string]int, error) {
-	counts := make(map[string]int)
+// newSketch returns a sketch using about budget bytes, split evenly between
+// Count-Min and Space-Saving
+func newSketch(budget int64) *sketch {
+	width := budget / 2 / (8 * sketchDepth)
+	if width < 1 {
+		width = 1
+	}
+	k := budget / 2 / ssEntryBytes
+	if k < 1 {
+		k = 1
+	}
+	return &sketch{
+		cms:   newCountMinSketch(int(width), sketchDepth),
+		heavy: newSpaceSaving(int(k)),
+	}
+}
+
+func (s *sketch) add(term string) {
+	s.cms.add(term, 1)
+	s.heavy.add(term, 1)
+	s.total++
+}
+
+func (s *sketch) merge(o *sketch) {
+	s.cms.merge(o.cms)
+	s.heavy.merge(o.heavy)
+	s.total += o.total
+}
+
+type heavyHitter struct {
+	term  string
+	count uint64 // upper bound on the true count
+	lower uint64 // guaranteed lower bound on the true count
+}
+
+// top returns the first n tracked terms in the given order
+func (s *sketch) top(n int, order ordering) []heavyHitter {
+	hits := make(map[string]heavyHitter, len(s.heavy.entries))
+	counts := make(map[string]int, len(s.heavy.entries))
+	for _, e := range s.heavy.entries {
+		count := e.count
+		if est := s.cms.estimate(e.term); est < count {
+			count = est
+		}
+		hits[e.term] = heavyHitter{term: e.term, count: count, lower: e.count - e.err}
+		counts[e.term] = int(count)
+	}
+
+	var out []heavyHitter
+	for _, term := range topNBy(counts, n, order) {
+		out = append(out, hits[term])
+	}
+	return out
+}
+
+func sketchFreq(r io.Reader, budget int64) (*sketch, error) {
+	s := newSketch(budget)
+	err := countWords(r, s)
+	return s, err
+}
+
+// filesSketch is the approximate version of filesFreq, the memory budget is
+// split between the workers
+func filesSketch(paths []string, workers int, budget int64) (*sketch, error) {
+	if workers < 1 {
+		workers = 1
+	}
+	shards := make([]counter, workers)
+	for i := range shards {
+		shards[i] = newSketch(budget / int64(workers))
+	}
+	if err := countFiles(paths, shards); err != nil {
+		return nil, err
+	}
+
+	s := shards[0].(*sketch)
+	for _, shard := range shards[1:] {
+		s.merge(shard.(*sketch))
+	}
+	return s, nil
+}
+
+// parseSize parses sizes such as 512, 64KB, 16MB or 1GB
+func parseSize(s string) (int64, error) {
+	units := []struct {
+		suffix string
+		size   int64
+	}{
+		{"GB", 1 << 30},
+		{"MB", 1 << 20},
+		{"KB", 1 << 10},
+		{"B", 1},
+	}
+
+	mult := int64(1)
+	num := strings.ToUpper(strings.TrimSpace(s))
+	for _, u := range units {
+		if strings.HasSuffix(num, u.suffix) {
+			num, mult = strings.TrimSpace(strings.TrimSuffix(num, u.suffix)), u.size
+			break
+		}
+	}
+
+	n, err := strconv.ParseInt(num, 10, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("bad size - %q", s)
+	}
+	return n * mult, nil
+}
+
+// liveCounter is a counter for -follow mode, snapshot returns the current
+// counts. The returned map belongs to the counter and is only valid until
+// the next call to add.
+type liveCounter interface {
+	counter
+	snapshot(now time.Time) map[string]int
+}
+
+func (c wordCounts) snapshot(now time.Time) map[string]int {
+	return c
+}
+
+// windowCounts counts the terms seen in the last window, in buckets of
+// window/windowBuckets that expire as a whole
+type windowCounts struct {
+	window  time.Duration
+	size    time.Duration // of a bucket
+	buckets []countBucket // oldest first
+	total   map[string]int
+}
+
+type countBucket struct {
+	start  time.Time
+	counts map[string]int
+}
+
+const windowBuckets = 12
+
+func newWindowCounts(window time.Duration) *windowCounts {
+	size := window / windowBuckets
+	if size < time.Second {
+		size = time.Second
+	}
+	return &windowCounts{window: window, size: size, total: make(map[string]int)}
+}
+
+func (c *windowCounts) add(term string) {
+	now := time.Now()
+	last := len(c.buckets) - 1
+	if last < 0 || now.Sub(c.buckets[last].start) >= c.size {
+		c.buckets = append(c.buckets, countBucket{start: now, counts: make(map[string]int)})
+		last++
+	}
+	c.buckets[last].counts[term]++
+	c.total[term]++
+}
+
+func (c *windowCounts) snapshot(now time.Time) map[string]int {
+	cutoff := now.Add(-c.window)
+	for len(c.buckets) > 0 && !c.buckets[0].start.Add(c.size).After(cutoff) {
+		for term, n := range c.buckets[0].counts {
+			if c.total[term] -= n; c.total[term] == 0 {
+				delete(c.total, term)
+			}
+		}
+		c.buckets = c.buckets[1:]
+	}
+	return c.total
+}
+
+// decayCounts weighs every occurrence by 2^(-age/halfLife). Weights are
+// stored relative to epoch so add doesn't need to touch every term.
+type decayCounts struct {
+	lambda  float64 // ln(2) / half life, per second
+	epoch   time.Time
+	weights map[string]float64
+	counts  map[string]int
+}
+
+func newDecayCounts(halfLife time.Duration) *decayCounts {
+	return &decayCounts{
+		lambda:  math.Ln2 / halfLife.Seconds(),
+		epoch:   time.Now(),
+		weights: make(map[string]float64),
+		counts:  make(map[string]int),
+	}
+}
+
+// maxExponent is how far weights may grow past the epoch before they're
+// rebased, well below where math.Exp overflows (about 709)
+const maxExponent = 100
+
+func (c *decayCounts) add(term string) {
+	now := time.Now()
+	if c.lambda*now.Sub(c.epoch).Seconds() > maxExponent {
+		c.rebase(now)
+	}
+	c.weights[term] += math.Exp(c.lambda * now.Sub(c.epoch).Seconds())
+}
+
+// rebase moves the epoch to now, scaling the weights down to match
+func (c *decayCounts) rebase(now time.Time) {
+	scale := math.Exp(-c.lambda * now.Sub(c.epoch).Seconds())
+	for term, w := range c.weights {
+		c.weights[term] = w * scale
+	}
+	c.epoch = now
+}
+
+// snapshot returns the decayed counts rounded to integers, terms that
+// decayed below one half are forgotten
+func (c *decayCounts) snapshot(now time.Time) map[string]int {
+	scale := math.Exp(-c.lambda * now.Sub(c.epoch).Seconds())
+	for term := range c.counts {
+		delete(c.counts, term)
+	}
+	for term, w := range c.weights {
+		n := math.Round(w * scale)
+		if n < 1 {
+			delete(c.weights, term)
+			continue
+		}
+		c.counts[term] = int(n)
+	}
+
+	// Rebase before the weights of new terms overflow
+	if c.lambda*now.Sub(c.epoch).Seconds() > maxExponent {
+		c.rebase(now)
+	}
+	return c.counts
+}
+
+// readLines sends the lines of r to out and closes it at EOF
+func readLines(r io.Reader, out chan<- string) error {
+	defer close(out)
 	s := bufio.NewScanner(r)
 	for s.Scan() {
-		for _, w := range wordRe.FindAllString(s.Text(), -1) {
-			counts[strings.ToLower(w)]++
+		out <- s.Text()
+	}
+	return s.Err()
+}
+
+// tailLines sends lines appended to path to out, like "tail -f". It starts
+// at the current end of the file and starts over if the file is truncated.
+func tailLines(path string, poll time.Duration, out chan<- string) error {
+	defer close(out)
+
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	offset, err := file.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+
+	r := bufio.NewReader(file)
+	var partial string
+	for {
+		chunk, err := r.ReadString('\n')
+		offset += int64(len(chunk))
+		partial += chunk
+		switch {
+		case err == nil:
+			out <- strings.TrimRight(partial, "\r\n")
+			partial = ""
+			continue
+		case err != io.EOF:
+			return err
+		}
+
+		time.Sleep(poll)
+		fi, err := file.Stat()
+		if err != nil {
+			return err
+		}
+		if fi.Size() < offset {
+			if offset, err = file.Seek(0, io.SeekStart); err != nil {
+				return err
+			}
+			partial = ""
+		}
+		r.Reset(file)
+	}
+}
+
+// followLines counts lines as they arrive and calls emit with the current
+// counts every interval and every "every" lines (zero disables either), and
+// once more when lines is closed
+func followLines(lines <-chan string, live liveCounter, interval time.Duration, every int, emit func(map[string]int) error) error {
+	t := newTermCounter(live)
+
+	var tick <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	n := 0 // lines read
+	for {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				return emit(live.snapshot(time.Now()))
+			}
+			t.line(line)
+			n++
+			if every > 0 && n%every == 0 {
+				if err := emit(live.snapshot(time.Now())); err != nil {
+					return err
+				}
+			}
+		case now := <-tick:
+			if err := emit(live.snapshot(now)); err != nil {
+				return err
+			}
 		}
 	}
-	return counts, s.Err()
 }
 
-func keys(m map[string]int) []string {
-	keys := make([]string, 0, len(m))
-	for k := range m {
-		keys = append(keys, k)
+// result is one output row. Share is the fraction of all counted terms,
+// Cumulative the running sum of Share down to this row.
+type result struct {
+	Word       string   `json:"word"`
+	Count      int      `json:"count"`
+	Share      float64  `json:"share"`
+	Cumulative float64  `json:"cumulative"`
+	PMI        *float64 `json:"pmi,omitempty"`
+	Error      *int     `json:"error,omitempty"` // -approx, Count may be over by this much
+}
+
+// gramTotal returns the number of n-grams in freqs, skipping the unigrams
+// counted for -pmi
+func gramTotal(freqs map[string]int, n int) int {
+	total := 0
+	for gram, c := range freqs {
+		if n == 1 || gramLen(gram) == n {
+			total += c
+		}
 	}
-	return keys
+	return total
 }
 
-func topN(freqs map[string]int, n int) []string {
-	words := keys(freqs)
-	less := func(i, j int) bool {
-		// Sort in reverse order
-		return freqs[words[i]] > freqs[words[j]]
+func newResults(words []string, count func(string) int, total int) []result {
+	rows := make([]result, len(words))
+	cum := 0.0
+	for i, w := range words {
+		c := count(w)
+		share := 0.0
+		if total > 0 {
+			share = float64(c) / float64(total)
+		}
+		cum += share
+		rows[i] = result{Word: w, Count: c, Share: share, Cumulative: cum}
+	}
+	return rows
+}
+
+func freqResults(freqs map[string]int, words []string) []result {
+	count := func(w string) int { return freqs[w] }
+	return newResults(words, count, gramTotal(freqs, config.ngram))
+}
+
+func pmiResults(freqs map[string]int, scores map[string]float64, grams []string) []result {
+	rows := freqResults(freqs, grams)
+	for i := range rows {
+		pmi := scores[rows[i].Word]
+		rows[i].PMI = &pmi
+	}
+	return rows
+}
+
+func sketchResults(s *sketch, n int, order ordering) []result {
+	hits := s.top(n, order)
+	words := make([]string, len(hits))
+	byTerm := make(map[string]heavyHitter, len(hits))
+	for i, h := range hits {
+		words[i] = h.term
+		byTerm[h.term] = h
+	}
+
+	count := func(w string) int { return int(byTerm[w].count) }
+	rows := newResults(words, count, int(s.total))
+	for i := range rows {
+		h := byTerm[rows[i].Word]
+		e := int(h.count - h.lower)
+		rows[i].Error = &e
+	}
+	return rows
+}
+
+var writers = map[string]func(io.Writer, []result) error{
+	"text":     writeText,
+	"json":     writeJSON,
+	"csv":      writeCSV,
+	"tsv":      writeTSV,
+	"markdown": writeMarkdown,
+}
+
+// writeText writes the classic "word<TAB>count" lines, followed by the PMI
+// score or the ±error when there is one
+func writeText(w io.Writer, rows []result) error {
+	for _, r := range rows {
+		var err error
+		switch {
+		case r.PMI != nil:
+			_, err = fmt.Fprintf(w, "%v\t%d\t%.3f\n", r.Word, r.Count, *r.PMI)
+		case r.Error != nil:
+			_, err = fmt.Fprintf(w, "%v\t%d\t±%d\n", r.Word, r.Count, *r.Error)
+		default:
+			_, err = fmt.Fprintf(w, "%v\t%d\n", r.Word, r.Count)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeJSON(w io.Writer, rows []result) error {
+	if rows == nil {
+		rows = []result{} // "[]" rather than "null"
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(rows)
+}
+
+// columns returns the header and cell values of rows for the tabular formats
+func columns(rows []result) ([]string, [][]string) {
+	header := []string{"word", "count", "share", "cumulative"}
+	if len(rows) > 0 && rows[0].PMI != nil {
+		header = append(header, "pmi")
+	}
+	if len(rows) > 0 && rows[0].Error != nil {
+		header = append(header, "error")
 	}
-	sort.Slice(words, less)
-	return words[:n]
+
+	cells := make([][]string, len(rows))
+	for i, r := range rows {
+		row := []string{
+			r.Word,
+			strconv.Itoa(r.Count),
+			strconv.FormatFloat(r.Share, 'f', 6, 64),
+			strconv.FormatFloat(r.Cumulative, 'f', 6, 64),
+		}
+		if r.PMI != nil {
+			row = append(row, strconv.FormatFloat(*r.PMI, 'f', 3, 64))
+		}
+		if r.Error != nil {
+			row = append(row, strconv.Itoa(*r.Error))
+		}
+		cells[i] = row
+	}
+	return header, cells
+}
+
+func writeCSV(w io.Writer, rows []result) error {
+	header, cells := columns(rows)
+	return writeCSVTable(w, header, cells)
+}
+
+func writeTSV(w io.Writer, rows []result) error {
+	header, cells := columns(rows)
+	return writeTSVTable(w, header, cells)
+}
+
+// writeMarkdown writes a table with shares as percentages
+func writeMarkdown(w io.Writer, rows []result) error {
+	header, cells := columns(rows)
+	for i, row := range cells {
+		row[2] = fmt.Sprintf("%.2f%%", 100*rows[i].Share)
+		row[3] = fmt.Sprintf("%.2f%%", 100*rows[i].Cumulative)
+	}
+	return writeMarkdownTable(w, header, cells)
+}
+
+func writeCSVTable(w io.Writer, header []string, cells [][]string) error {
+	cw := csv.NewWriter(w)
+	cw.Write(header)
+	cw.WriteAll(cells) // WriteAll flushes
+	return cw.Error()
+}
+
+func writeTSVTable(w io.Writer, header []string, cells [][]string) error {
+	if _, err := fmt.Fprintln(w, strings.Join(header, "\t")); err != nil {
+		return err
+	}
+	for _, row := range cells {
+		if _, err := fmt.Fprintln(w, strings.Join(row, "\t")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeMarkdownTable writes a table with the first column left aligned and
+// the rest (numbers) right aligned
+func writeMarkdownTable(w io.Writer, header []string, cells [][]string) error {
+	sep := make([]string, len(header))
+	for i := range sep {
+		sep[i] = "---:"
+	}
+	sep[0] = "---"
+
+	lines := append([][]string{header, sep}, cells...)
+	for _, row := range lines {
+		row[0] = strings.ReplaceAll(row[0], "|", `\|`)
+		if _, err := fmt.Fprintf(w, "| %s |\n", strings.Join(row, " | ")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// countOptions are the flags controlling how text is turned into terms,
+// shared by the default command and diff
+type countOptions struct {
+	workers              int
+	apostrophes, hyphens bool
+	expr                 string
+	stopLangs, stopFile  string
+	input                string
+	code                 bool
+}
+
+func (o *countOptions) register(fs *flag.FlagSet) {
+	fs.IntVar(&o.workers, "workers", runtime.NumCPU(), "number of files to process in parallel")
+	fs.BoolVar(&config.fold, "fold", true, "fold case so \"Go\" and \"go\" are the same word")
+	fs.BoolVar(&o.apostrophes, "apostrophes", true, "keep apostrophes inside words (don't)")
+	fs.BoolVar(&o.hyphens, "hyphens", false, "keep hyphens inside words (well-known)")
+	fs.StringVar(&o.expr, "regexp", "", "custom regular expression matching a word (overrides -apostrophes and -hyphens)")
+	fs.StringVar(&o.stopLangs, "stop", "", fmt.Sprintf("comma separated languages whose stop words are ignored (%s)", strings.Join(stopLanguages(), ",")))
+	fs.StringVar(&o.stopFile, "stopwords", "", "file with additional stop words, one per line")
+	fs.BoolVar(&config.stem, "stem", false, "count English words by their Porter stem (running, runs -> run)")
+	fs.IntVar(&config.ngram, "ngram", 1, "count sequences of N words")
+	fs.StringVar(&o.input, "input", "text", "input type: text or markdown (prose only, without front matter, code and markup)")
+	fs.BoolVar(&o.code, "code", false, "with -input markdown, count identifiers in code instead of prose")
+}
+
+// apply checks the options and sets up config accordingly
+func (o *countOptions) apply() error {
+	if config.ngram < 1 {
+		return fmt.Errorf("-ngram must be positive")
+	}
+
+	newFilter, ok := inputFilters[o.input]
+	if !ok {
+		return fmt.Errorf("unknown -input - %s", o.input)
+	}
+	if o.code && newFilter == nil {
+		return fmt.Errorf("-code requires -input markdown")
+	}
+	if newFilter != nil {
+		code := o.code
+		config.filter = func() lineFilter { return newFilter(code) }
+	}
+
+	expr := o.expr
+	if o.code && expr == "" {
+		expr = identRe
+	}
+	if expr != "" {
+		tok, err := NewRegexpTokenizer(expr)
+		if err != nil {
+			return fmt.Errorf("bad -regexp - %s", err)
+		}
+		config.tokenizer = tok
+	} else {
+		config.tokenizer = &UnicodeTokenizer{Apostrophes: o.apostrophes, Hyphens: o.hyphens}
+	}
+
+	stopWords, err := loadStopWords(o.stopLangs, o.stopFile)
+	if err != nil {
+		return err
+	}
+	config.stopWords = stopWords
+	return nil
 }
 
 func main() {
-	var count int
+	if len(os.Args) > 1 && os.Args[1] == "diff" {
+		if err := runDiff(os.Args[2:]); err != nil {
+			log.Fatalf("error: %s", err)
+		}
+		return
+	}
+
+	var (
+		opts             countOptions
+		count, minCount  int
+		pmi, approx      bool
+		memory, sortBy   string
+		format           string
+		follow           bool
+		interval         time.Duration
+		every            int
+		window, halfLife time.Duration
+	)
 
+	opts.register(flag.CommandLine)
 	flag.IntVar(&count, "count", 10, "number of top words to show")
+	flag.BoolVar(&pmi, "pmi", false, "rank n-grams by pointwise mutual information instead of frequency")
+	flag.IntVar(&minCount, "min-count", 3, "ignore n-grams seen fewer times than this with -pmi")
+	flag.BoolVar(&approx, "approx", false, "approximate counts in bounded memory (Count-Min Sketch and Space-Saving)")
+	flag.StringVar(&memory, "memory", "64MB", "memory budget for -approx")
+	flag.StringVar(&sortBy, "sort", "count", "output order: count (ties by word), count-asc or word")
+	flag.StringVar(&format, "format", "text", "output format: text, json, csv, tsv or markdown")
+	flag.BoolVar(&follow, "follow", false, "keep reading stdin, or the new lines of FILE, and print the top words periodically")
+	flag.DurationVar(&interval, "interval", 5*time.Second, "with -follow, print the top words this often (0 to disable)")
+	flag.IntVar(&every, "every", 0, "with -follow, also print the top words every N lines")
+	flag.DurationVar(&window, "window", 0, "with -follow, only count words seen in this sliding time window")
+	flag.DurationVar(&halfLife, "half-life", 0, "with -follow, decay counts exponentially with this half life")
 	flag.Usage = func() {
-		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s\nword frequency\n\noptions:\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [FILE|DIR ...]\n       %s diff A B\nword frequency (reads stdin if no files given)\n\noptions:\n", os.Args[0], os.Args[0])
 		flag.PrintDefaults()
 	}
 	flag.Parse()
 
-	freqs, err := wordFreq(os.Stdin)
-	if err != nil {
-		log.Fatal(err)
+	if err := opts.apply(); err != nil {
+		log.Fatalf("error: %s", err)
+	}
+	if pmi && config.ngram < 2 {
+		log.Fatalf("error: -pmi requires -ngram 2 or more")
+	}
+	if pmi && approx {
+		log.Fatalf("error: -pmi can't be used with -approx")
+	}
+	if follow && (pmi || approx) {
+		log.Fatalf("error: -follow can't be used with -pmi or -approx")
+	}
+	config.unigrams = pmi
+
+	order, ok := orderings[sortBy]
+	if !ok {
+		log.Fatalf("error: unknown -sort - %s", sortBy)
+	}
+	if pmi && sortBy != "count" {
+		log.Fatalf("error: -pmi output is always ordered by score")
+	}
+
+	write, ok := writers[format]
+	if !ok {
+		log.Fatalf("error: unknown -format - %s", format)
+	}
+
+	var (
+		rows []result
+		err  error
+	)
+	switch {
+	case follow:
+		snapshots := 0
+		emit := func(freqs map[string]int) error {
+			// Blank line between snapshots
+			if snapshots++; snapshots > 1 {
+				fmt.Println()
+			}
+			return write(os.Stdout, freqResults(freqs, topNBy(freqs, count, order)))
+		}
+		if err := runFollow(window, halfLife, interval, every, emit); err != nil {
+			log.Fatalf("error: %s", err)
+		}
+		return
+	case approx:
+		budget, err := parseSize(memory)
+		if err != nil {
+			log.Fatalf("error: -memory: %s", err)
+		}
+		var s *sketch
+		if flag.NArg() == 0 {
+			s, err = sketchFreq(os.Stdin, budget)
+		} else {
+			s, err = filesSketch(flag.Args(), opts.workers, budget)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		rows = sketchResults(s, count, order)
+		reportSketch(s)
+	default:
+		var freqs map[string]int
+		if flag.NArg() == 0 {
+			freqs, err = wordFreq(os.Stdin)
+		} else {
+			freqs, err = filesFreq(flag.Args(), opts.workers)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if pmi {
+			scores := pmiScores(freqs, config.ngram, minCount)
+			rows = pmiResults(freqs, scores, topScores(scores, count))
+		} else {
+			rows = freqResults(freqs, topNBy(freqs, count, order))
+		}
+	}
+
+	if err := write(os.Stdout, rows); err != nil {
+		log.Fatalf("error: %s", err)
+	}
+}
+
+// reportSketch prints the overall error bounds of approximate counts to stderr
+func reportSketch(s *sketch) {
+	eps := s.cms.epsilon() * float64(s.total)
+	fmt.Fprintf(os.Stderr, "approximate counts over %d terms: count-min error <= %.0f with %.1f%% confidence, "+
+		"terms seen more than %d times are never missed\n",
+		s.total, eps, 100*(1-s.cms.delta()), s.total/uint64(s.heavy.k))
+}
+
+// runFollow implements -follow on stdin or on the file given as argument
+func runFollow(window, halfLife, interval time.Duration, every int, emit func(map[string]int) error) error {
+	var live liveCounter
+	switch {
+	case window > 0 && halfLife > 0:
+		return fmt.Errorf("-window and -half-life are mutually exclusive")
+	case window > 0:
+		live = newWindowCounts(window)
+	case halfLife > 0:
+		live = newDecayCounts(halfLife)
+	default:
+		live = wordCounts(make(map[string]int))
+	}
+
+	lines := make(chan string)
+	errc := make(chan error, 1)
+	switch flag.NArg() {
+	case 0:
+		go func() { errc <- readLines(os.Stdin, lines) }()
+	case 1:
+		go func() { errc <- tailLines(flag.Arg(0), 250*time.Millisecond, lines) }()
+	default:
+		return fmt.Errorf("-follow works on stdin or a single file")
+	}
+
+	if err := followLines(lines, live, interval, every, emit); err != nil {
+		return err
+	}
+	return <-errc
+}
+
+// diffResult compares the frequency of a word in corpora A and B. Freq is
+// per million terms, LogRatio is log2(FreqB/FreqA) with counts smoothed by
+// 0.5 so words missing from one side still have a ratio.
+type diffResult struct {
+	Word     string  `json:"word"`
+	CountA   int     `json:"count_a"`
+	CountB   int     `json:"count_b"`
+	FreqA    float64 `json:"freq_a"`
+	FreqB    float64 `json:"freq_b"`
+	LogRatio float64 `json:"log_ratio"`
+	Score    float64 `json:"score"`
+}
+
+// diffScorers are the -score options. a and b are the counts of a word in
+// each corpus, totalA and totalB the corpus sizes.
+var diffScorers = map[string]func(a, b, totalA, totalB float64) float64{
+	"ll":   logLikelihood,
+	"chi2": chiSquared,
+}
+
+// logLikelihood is Dunning's G² statistic (as used by Rayson and Garside for
+// corpus comparison)
+func logLikelihood(a, b, totalA, totalB float64) float64 {
+	expA := totalA * (a + b) / (totalA + totalB)
+	expB := totalB * (a + b) / (totalA + totalB)
+	g2 := 0.0
+	if a > 0 {
+		g2 += a * math.Log(a/expA)
+	}
+	if b > 0 {
+		g2 += b * math.Log(b/expB)
+	}
+	return 2 * g2
+}
+
+// chiSquared is Pearson's χ² for the 2x2 table of word/other counts
+func chiSquared(a, b, totalA, totalB float64) float64 {
+	c, d := totalA-a, totalB-b
+	n := totalA + totalB
+	denom := (a + b) * (c + d) * totalA * totalB
+	if denom == 0 {
+		return 0
+	}
+	return n * math.Pow(a*d-b*c, 2) / denom
+}
+
+// diffCounts scores every word seen at least minCount times in a and b
+// together
+func diffCounts(a, b map[string]int, minCount int, score func(a, b, totalA, totalB float64) float64) []diffResult {
+	totalA := float64(gramTotal(a, config.ngram))
+	totalB := float64(gramTotal(b, config.ngram))
+	if totalA == 0 || totalB == 0 {
+		return nil
+	}
+
+	var rows []diffResult
+	seen := make(map[string]bool)
+	for _, counts := range []map[string]int{a, b} {
+		for w := range counts {
+			if seen[w] || a[w]+b[w] < minCount {
+				continue
+			}
+			seen[w] = true
+
+			ca, cb := float64(a[w]), float64(b[w])
+			rows = append(rows, diffResult{
+				Word:     w,
+				CountA:   a[w],
+				CountB:   b[w],
+				FreqA:    1e6 * ca / totalA,
+				FreqB:    1e6 * cb / totalB,
+				LogRatio: math.Log2(((cb + 0.5) / totalB) / ((ca + 0.5) / totalA)),
+				Score:    score(ca, cb, totalA, totalB),
+			})
+		}
+	}
+	return rows
+}
+
+// topDiffs returns the n rows with the highest score, ties broken by word
+func topDiffs(rows []diffResult, n int) []diffResult {
+	byWord := make(map[string]diffResult, len(rows))
+	for _, r := range rows {
+		byWord[r.Word] = r
+	}
+
+	t := newTopK(n, func(a, b string) bool {
+		if byWord[a].Score != byWord[b].Score {
+			return byWord[a].Score > byWord[b].Score
+		}
+		return a < b
+	})
+	for w := range byWord {
+		t.offer(w)
+	}
+
+	words := t.sorted()
+	top := make([]diffResult, 0, len(words))
+	for _, w := range words {
+		top = append(top, byWord[w])
+	}
+	return top
+}
+
+func diffColumns(rows []diffResult) ([]string, [][]string) {
+	header := []string{"word", "count_a", "count_b", "freq_a", "freq_b", "log_ratio", "score"}
+	cells := make([][]string, len(rows))
+	for i, r := range rows {
+		cells[i] = []string{
+			r.Word,
+			strconv.Itoa(r.CountA),
+			strconv.Itoa(r.CountB),
+			strconv.FormatFloat(r.FreqA, 'f', 2, 64),
+			strconv.FormatFloat(r.FreqB, 'f', 2, 64),
+			strconv.FormatFloat(r.LogRatio, 'f', 3, 64),
+			strconv.FormatFloat(r.Score, 'f', 3, 64),
+		}
+	}
+	return header, cells
+}
+
+var diffWriters = map[string]func(io.Writer, []diffResult) error{
+	"text": func(w io.Writer, rows []diffResult) error {
+		for _, r := range rows {
+			if _, err := fmt.Fprintf(w, "%v\t%d\t%d\t%+.3f\t%.3f\n", r.Word, r.CountA, r.CountB, r.LogRatio, r.Score); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	"json": func(w io.Writer, rows []diffResult) error {
+		if rows == nil {
+			rows = []diffResult{}
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(rows)
+	},
+	"csv": func(w io.Writer, rows []diffResult) error {
+		header, cells := diffColumns(rows)
+		return writeCSVTable(w, header, cells)
+	},
+	"tsv": func(w io.Writer, rows []diffResult) error {
+		header, cells := diffColumns(rows)
+		return writeTSVTable(w, header, cells)
+	},
+	"markdown": func(w io.Writer, rows []diffResult) error {
+		header, cells := diffColumns(rows)
+		return writeMarkdownTable(w, header, cells)
+	},
+}
+
+const diffUsage = `usage: %s diff [options] A B
+Show the words whose relative frequency changed the most between A and B
+(files or directories). In text output the columns are word, count in A,
+count in B, log2 ratio (positive means more frequent in B) and score.
+
+Options:
+`
+
+func runDiff(args []string) error {
+	var (
+		opts            countOptions
+		count, minCount int
+		scoreBy, format string
+	)
+
+	fs := flag.NewFlagSet("diff", flag.ContinueOnError)
+	opts.register(fs)
+	fs.IntVar(&count, "count", 10, "number of words to show")
+	fs.IntVar(&minCount, "min-count", 5, "ignore words seen fewer times than this in A and B together")
+	fs.StringVar(&scoreBy, "score", "ll", "significance score: ll (log-likelihood) or chi2")
+	fs.StringVar(&format, "format", "text", "output format: text, json, csv, tsv or markdown")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), diffUsage, os.Args[0])
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		return fmt.Errorf("wrong number of arguments")
+	}
+	if err := opts.apply(); err != nil {
+		return err
+	}
+
+	score, ok := diffScorers[scoreBy]
+	if !ok {
+		return fmt.Errorf("unknown -score - %s", scoreBy)
+	}
+	write, ok := diffWriters[format]
+	if !ok {
+		return fmt.Errorf("unknown -format - %s", format)
 	}
 
-	for _, w := range topN(freqs, count) {
-		fmt.Printf("%v\t%d\n", w, freqs[w])
+	a, err := filesFreq([]string{fs.Arg(0)}, opts.workers)
+	if err != nil {
+		return err
+	}
+	b, err := filesFreq([]string{fs.Arg(1)}, opts.workers)
+	if err != nil {
+		return err
 	}
+
+	rows := topDiffs(diffCounts(a, b, minCount, score), count)
+	return write(os.Stdout, rows)
 }
@@ -1,19 +1,44 @@
 package main
 
 import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
 	"flag"
 	"fmt"
 	"log"
+	"math/big"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
 	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
 )
 
 var config struct {
-	port int
-	host string
+	port            int
+	host            string
+	shutdownTimeout time.Duration
+	drainDelay      time.Duration
+	tlsCert         string
+	tlsKey          string
+	tlsSelfSigned   bool
+	redirectPort    int
 }
 
+// ready is 1 while the server takes traffic and 0 once it shuts down, when
+// /ready fails
+var ready int32
+
 const (
 	usage = `usage: %s
 Run HTTP server
@@ -25,26 +50,45 @@ Options:
 func main() {
 	flag.Var(PortVar(&config.port), "port", "port to listen on")
 	flag.StringVar(&config.host, "host", config.host, "host to listen on")
+	flag.DurationVar(&config.shutdownTimeout, "shutdown-timeout", config.shutdownTimeout, "time in-flight requests have to finish on shutdown")
+	flag.DurationVar(&config.drainDelay, "drain-delay", config.drainDelay, "time to keep serving with /ready failing before shutting down, 0 when not behind a load balancer")
+	flag.StringVar(&config.tlsCert, "tls-cert", config.tlsCert, "TLS certificate file, reloaded on SIGHUP")
+	flag.StringVar(&config.tlsKey, "tls-key", config.tlsKey, "TLS key file")
+	flag.BoolVar(&config.tlsSelfSigned, "tls-self-signed", config.tlsSelfSigned, "serve TLS with a generated self-signed certificate, for development")
+	flag.Var(OptionalPortVar(&config.redirectPort), "redirect-port", "port to redirect HTTP to HTTPS from, 0 for none")
 	flag.Usage = func() {
 		fmt.Fprintf(flag.CommandLine.Output(), usage, os.Args[0])
 		flag.PrintDefaults()
 	}
 	flag.Parse()
+	if config.redirectPort != 0 && config.redirectPort == config.port {
+		log.Fatalf("error: -redirect-port and -port are both %d", config.port)
+	}
 
 	http.HandleFunc("/", handler)
+	http.HandleFunc("/ready", readyHandler)
+	tlsConf, err := tlsConfig()
+	if err != nil {
+		log.Fatalf("error: %s", err)
+	}
 	addr := fmt.Sprintf("%s:%d", config.host, config.port)
-	fmt.Printf("server ready on %s\n", addr)
-	if err := http.ListenAndServe(addr, nil); err != nil {
+	if err := serve(&http.Server{Addr: addr, TLSConfig: tlsConf}); err != nil {
 		log.Fatalf("error: %s", err)
 	}
 }
 
 func PortVar(port *int) *portVar {
-	return &portVar{port}
+	return &portVar{port: port}
+}
+
+// OptionalPortVar is a PortVar which also takes 0, for none
+func OptionalPortVar(port *int) *portVar {
+	return &portVar{port: port, optional: true}
 }
 
 type portVar struct {
-	port *int
+	port     *int
+	optional bool
 }
 
 func (p *portVar) String() string {
@@ -61,7 +105,10 @@ func (p *portVar) Set(s string) error {
 		return err
 	}
 
-	const minPort, maxPort = 1, 65535
+	minPort, maxPort := 1, 65535
+	if p.optional {
+		minPort = 0
+	}
 	if val < minPort || val > maxPort {
 		return fmt.Errorf("port %d out of range [%d:%d]", val, minPort, maxPort)
 	}
@@ -70,6 +117,192 @@ func (p *portVar) Set(s string) error {
 	return nil
 }
 
+// serve runs srv until SIGINT or SIGTERM, then shuts it down gracefully.
+// With TLS it serves HTTPS and, if config.redirectPort is set, redirects
+// plain HTTP on that port.
+func serve(srv *http.Server) error {
+	servers := []*http.Server{srv}
+	if srv.TLSConfig != nil && config.redirectPort != 0 {
+		redirect := &http.Server{
+			Addr:    fmt.Sprintf("%s:%d", config.host, config.redirectPort),
+			Handler: http.HandlerFunc(redirectHandler),
+		}
+		servers = append(servers, redirect)
+		go func() {
+			fmt.Printf("redirecting HTTP on %s\n", redirect.Addr)
+			if err := redirect.ListenAndServe(); err != http.ErrServerClosed {
+				log.Fatalf("error: %s", err)
+			}
+		}()
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- shutdownOnSignal(servers...) }()
+
+	atomic.StoreInt32(&ready, 1)
+	var err error
+	if srv.TLSConfig != nil {
+		fmt.Printf("server ready on https://%s\n", srv.Addr)
+		err = srv.ListenAndServeTLS("", "")
+	} else {
+		fmt.Printf("server ready on %s\n", srv.Addr)
+		err = srv.ListenAndServe()
+	}
+	if err != http.ErrServerClosed {
+		return err
+	}
+	return <-done
+}
+
+// shutdownOnSignal waits for SIGINT or SIGTERM, fails /ready for
+// config.drainDelay so load balancers stop sending traffic, then gives
+// in-flight requests config.shutdownTimeout to finish
+func shutdownOnSignal(servers ...*http.Server) error {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+	sig := <-sigs
+	signal.Stop(sigs) // a second signal kills the server right away
+
+	fmt.Printf("got %s, shutting down\n", sig)
+	atomic.StoreInt32(&ready, 0)
+	time.Sleep(config.drainDelay)
+
+	ctx, cancel := context.WithTimeout(context.Background(), config.shutdownTimeout)
+	defer cancel()
+	var err error
+	for _, srv := range servers {
+		if serr := srv.Shutdown(ctx); serr != nil && err == nil {
+			err = serr
+		}
+	}
+	return err
+}
+
+// tlsConfig returns the TLS configuration for the flags, nil when serving
+// plain HTTP
+func tlsConfig() (*tls.Config, error) {
+	hasFiles := config.tlsCert != "" || config.tlsKey != ""
+	switch {
+	case config.tlsSelfSigned && hasFiles:
+		return nil, fmt.Errorf("-tls-self-signed can't be used with -tls-cert or -tls-key")
+	case config.tlsSelfSigned:
+		cert, err := selfSigned(config.host)
+		if err != nil {
+			return nil, err
+		}
+		return &tls.Config{Certificates: []tls.Certificate{cert}}, nil
+	case hasFiles:
+		if config.tlsCert == "" || config.tlsKey == "" {
+			return nil, fmt.Errorf("both -tls-cert and -tls-key are needed")
+		}
+		certs := &certStore{certFile: config.tlsCert, keyFile: config.tlsKey}
+		if err := certs.load(); err != nil {
+			return nil, err
+		}
+		go certs.reloadOnSignal()
+		return &tls.Config{GetCertificate: certs.get}, nil
+	}
+	return nil, nil
+}
+
+// certStore holds a certificate loaded from files, which is reloaded on
+// SIGHUP so a renewed certificate is used without a restart
+type certStore struct {
+	certFile string
+	keyFile  string
+
+	mu   sync.RWMutex
+	cert *tls.Certificate
+}
+
+func (s *certStore) load() error {
+	cert, err := tls.LoadX509KeyPair(s.certFile, s.keyFile)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.cert = &cert
+	s.mu.Unlock()
+	return nil
+}
+
+// get is a tls.Config GetCertificate
+func (s *certStore) get(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.cert, nil
+}
+
+// reloadOnSignal reloads the certificate on SIGHUP, the current one is kept
+// if loading fails
+func (s *certStore) reloadOnSignal() {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGHUP)
+	for range sigs {
+		if err := s.load(); err != nil {
+			log.Printf("error: can't reload certificate - %s", err)
+			continue
+		}
+		fmt.Printf("certificate reloaded from %s\n", s.certFile)
+	}
+}
+
+// selfSigned generates a certificate for host and localhost, valid for a
+// year. It's kept in memory and browsers will warn about it, use it only for
+// local development.
+func selfSigned(host string) (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	now := time.Now()
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: host},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.AddDate(1, 0, 0),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		tmpl.IPAddresses = append(tmpl.IPAddresses, ip)
+	} else if host != "" && host != "localhost" {
+		tmpl.DNSNames = append(tmpl.DNSNames, host)
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, nil
+}
+
+// redirectHandler sends plain HTTP requests to the same URL on the HTTPS
+// server
+func redirectHandler(w http.ResponseWriter, r *http.Request) {
+	host := r.Host
+	if h, _, err := net.SplitHostPort(r.Host); err == nil {
+		host = h
+	}
+	host = strings.Trim(host, "[]")
+	switch {
+	case config.port != 443:
+		host = net.JoinHostPort(host, strconv.Itoa(config.port))
+	case strings.Contains(host, ":"): // IPv6
+		host = "[" + host + "]"
+	}
+	http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusMovedPermanently)
+}
+
 func init() {
 	// Set defaults
 	s := os.Getenv("HTTPD_PORT")
@@ -86,8 +319,46 @@ func init() {
 	} else {
 		config.host = "localhost"
 	}
+
+	d, err := time.ParseDuration(os.Getenv("HTTPD_SHUTDOWN_TIMEOUT"))
+	if err == nil {
+		config.shutdownTimeout = d
+	} else {
+		config.shutdownTimeout = 10 * time.Second
+	}
+
+	// Long enough for load balancers to see /ready fail, they usually
+	// check every few seconds
+	d, err = time.ParseDuration(os.Getenv("HTTPD_DRAIN_DELAY"))
+	if err == nil {
+		config.drainDelay = d
+	} else {
+		config.drainDelay = 5 * time.Second
+	}
+
+	config.tlsCert = os.Getenv("HTTPD_TLS_CERT")
+	config.tlsKey = os.Getenv("HTTPD_TLS_KEY")
+
+	b, err := strconv.ParseBool(os.Getenv("HTTPD_TLS_SELF_SIGNED"))
+	if err == nil {
+		config.tlsSelfSigned = b
+	}
+
+	if s := os.Getenv("HTTPD_REDIRECT_PORT"); s != "" {
+		if err := OptionalPortVar(&config.redirectPort).Set(s); err != nil {
+			log.Fatalf("error: HTTPD_REDIRECT_PORT - %s", err)
+		}
+	}
 }
 
 func handler(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprintf(w, "Hello Gophers\n")
 }
+
+func readyHandler(w http.ResponseWriter, r *http.Request) {
+	if atomic.LoadInt32(&ready) == 0 {
+		http.Error(w, "shutting down", http.StatusServiceUnavailable)
+		return
+	}
+	fmt.Fprintf(w, "ready\n")
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"bufio"
+	"bytes"
 	"compress/gzip"
 	"encoding/csv"
 	"encoding/json"
@@ -9,10 +10,14 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"runtime"
 	"strconv"
 	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
 )
 
 type rawline struct {
@@ -21,6 +26,171 @@ type rawline struct {
 	Repo  struct{ S string }
 }
 
+// status shows a line per reader with how far it is in its file and a line
+// with the files done, redrawn in place when stdout is a terminal. Otherwise
+// it prints a line per file. This file is downloaded on its own from the post,
+// so it can't use a progress package and sticks to the standard library.
+type status struct {
+	mu      sync.Mutex
+	tty     bool
+	width   int // of the terminal
+	readers []*readerStatus
+	total   int // files to process, 0 if unknown
+	done    int
+	lines   int // drawn last time
+}
+
+type readerStatus struct {
+	path string
+	size int64
+	read int64 // updated atomically
+}
+
+// countingReader adds the number of bytes read to n
+type countingReader struct {
+	r io.Reader
+	n *int64
+}
+
+func (c countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	atomic.AddInt64(c.n, int64(n))
+	return n, err
+}
+
+func newStatus(readers int) *status {
+	fi, err := os.Stdout.Stat()
+	s := &status{
+		tty:     err == nil && fi.Mode()&os.ModeCharDevice != 0,
+		readers: make([]*readerStatus, readers),
+	}
+	for i := range s.readers {
+		s.readers[i] = &readerStatus{}
+	}
+	if s.tty {
+		s.width = termWidth()
+	}
+	return s
+}
+
+// termWidth returns the width of the terminal from $COLUMNS or stty, 80 if
+// neither works (e.g. on Windows)
+func termWidth() int {
+	if n, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && n > 0 {
+		return n
+	}
+
+	cmd := exec.Command("stty", "size")
+	if tty, err := os.Open("/dev/tty"); err == nil {
+		defer tty.Close()
+		cmd.Stdin = tty
+	}
+	if out, err := cmd.Output(); err == nil {
+		var rows, cols int
+		if _, err := fmt.Sscan(string(out), &rows, &cols); err == nil && cols > 0 {
+			return cols
+		}
+	}
+	return 80
+}
+
+// shorten cuts the start of path so it's at most n runes long
+func shorten(path string, n int) string {
+	count := utf8.RuneCountInString(path)
+	if count <= n {
+		return path
+	}
+	if n <= 3 {
+		return ""
+	}
+	runes := []rune(path)
+	return "..." + string(runes[count-(n-3):])
+}
+
+// start records reader i starting on path, the returned reader counts the
+// bytes read from r
+func (s *status) start(i int, path string, size int64, r io.Reader) io.Reader {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rs := s.readers[i]
+	rs.path, rs.size = path, size
+	atomic.StoreInt64(&rs.read, 0)
+	if !s.tty {
+		fmt.Println("Processing file ", path)
+	}
+	return countingReader{r, &rs.read}
+}
+
+func (s *status) finish(i int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.readers[i].path = ""
+	s.done++
+}
+
+// draw moves the cursor up over the lines drawn last time and redraws them
+func (s *status) draw() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.tty {
+		return
+	}
+
+	var buf bytes.Buffer
+	if s.lines > 0 {
+		fmt.Fprintf(&buf, "\r\x1b[%dA", s.lines)
+	}
+	for i, rs := range s.readers {
+		if rs.path == "" {
+			fmt.Fprintf(&buf, "reader %d: idle\x1b[K\n", i)
+			continue
+		}
+		pct := int64(100)
+		if rs.size > 0 {
+			pct = atomic.LoadInt64(&rs.read) * 100 / rs.size
+		}
+		// Long lines wrap, which would throw off moving the cursor up
+		prefix := fmt.Sprintf("reader %d: %3d%% ", i, pct)
+		path := shorten(rs.path, s.width-len(prefix)-1)
+		fmt.Fprintf(&buf, "%s%s\x1b[K\n", prefix, path)
+	}
+	if s.total > 0 {
+		fmt.Fprintf(&buf, "files: %d/%d (%d%%)\x1b[K\n", s.done, s.total, s.done*100/s.total)
+	} else {
+		fmt.Fprintf(&buf, "files: %d\x1b[K\n", s.done)
+	}
+	os.Stdout.Write(buf.Bytes())
+	s.lines = len(s.readers) + 1
+}
+
+// run draws every interval until stop is closed, then draws once more
+func (s *status) run(interval time.Duration, stop <-chan struct{}, done chan<- struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		s.draw()
+		select {
+		case <-stop:
+			s.draw()
+			close(done)
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// countFiles returns the number of files under dir
+func countFiles(dir string) int {
+	n := 0
+	filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			n++
+		}
+		return nil
+	})
+	return n
+}
+
 func main() {
 	id := flag.String("id", "", "Input directory (contains dynamodb dump files)")
 	of := flag.String("of", "", "Output file name")
@@ -68,22 +238,30 @@ func main() {
 		wg.Done()
 	}(*of, datachan, writerdone)
 
+	st := newStatus(*nw)
+	if st.tty {
+		st.total = countFiles(*id)
+	}
+	statusstop, statusdone := make(chan struct{}), make(chan struct{})
+	go st.run(200*time.Millisecond, statusstop, statusdone)
+
 	pathchan := make(chan string)
 	readersdone := &sync.WaitGroup{}
 
 	for i := 0; i < *nw; i++ {
 		readersdone.Add(1)
-		go func(pathchan <-chan string, datachan chan<- rawline, wg *sync.WaitGroup) {
+		go func(i int, pathchan <-chan string, datachan chan<- rawline, wg *sync.WaitGroup) {
 			for path := range pathchan {
-
-				fmt.Println("Processing file ", path)
-
 				file, err := os.Open(path)
 				if err != nil {
 					panic(err)
 				}
+				var size int64
+				if fi, err := file.Stat(); err == nil {
+					size = fi.Size()
+				}
 
-				dec := json.NewDecoder(bufio.NewReader(file))
+				dec := json.NewDecoder(bufio.NewReader(st.start(i, path, size, file)))
 				var raw rawline
 
 				for {
@@ -103,10 +281,12 @@ func main() {
 
 					datachan <- raw
 				}
+				file.Close()
+				st.finish(i)
 			}
 
 			wg.Done()
-		}(pathchan, datachan, readersdone)
+		}(i, pathchan, datachan, readersdone)
 	}
 
 	err := filepath.Walk(*id, func(path string, info os.FileInfo, err error) error {
@@ -128,6 +308,8 @@ func main() {
 
 	close(pathchan)
 	readersdone.Wait()
+	close(statusstop)
+	<-statusdone
 	close(datachan)
 	writerdone.Wait()
 }